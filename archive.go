@@ -0,0 +1,314 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveFormats maps archive file suffixes to the format they are read as.
+// Longer suffixes come first so ".tar.gz" is not mistaken for ".gz".
+var archiveFormats = []struct {
+	suffix string
+	format string
+}{
+	{".tar.gz", "tar.gz"},
+	{".tgz", "tar.gz"},
+	{".tar.zst", "tar.zst"},
+	{".tzst", "tar.zst"},
+	{".tar", "tar"},
+	{".zip", "zip"},
+}
+
+// defaultMaxArchiveSize is the default --max-archive-size (512 MiB).
+const defaultMaxArchiveSize = 512 << 20
+
+// defaultMaxArchiveEntries is the default --max-archive-entries.
+const defaultMaxArchiveEntries = 100000
+
+// archiveFile is a regular file read from an archive into memory.
+type archiveFile struct {
+	path    string // Slash-separated, relative to the archive root
+	mode    fs.FileMode
+	modTime time.Time
+	content []byte
+}
+
+// archiveFormat returns the format of an archive input (local path or URL), or ""
+// when the input is not an archive. The query and fragment of URLs are ignored.
+func archiveFormat(input string) string {
+	name := input
+	if isWebURL(input) {
+		u, err := neturl.Parse(input)
+		if err != nil {
+			return ""
+		}
+		name = u.Path
+	} else if isDir(input) {
+		return "" // A directory that happens to be called "x.zip"
+	}
+	name = strings.ToLower(name)
+	for _, f := range archiveFormats {
+		if strings.HasSuffix(name, f.suffix) {
+			return f.format
+		}
+	}
+	return ""
+}
+
+// isArchiveInput reports whether the input is a .zip, .tar, .tar.gz/.tgz or
+// .tar.zst file, local or behind an http(s) URL.
+func isArchiveInput(input string) bool {
+	return archiveFormat(input) != ""
+}
+
+// processArchive reads an archive into memory and returns its files as a virtual
+// directory tree: paths are relative to the archive root and content is read from
+// an in-memory file system.
+// Nothing is extracted to disk. Entries that would escape the root, links and
+// special files are skipped; --max-archive-size and --max-archive-entries bound
+// the download and the extracted size.
+func processArchive(input string, langData *LoadedLanguageData) ([]FileInfo, error) {
+	if changeSelectionEnabled() {
+		return nil, fmt.Errorf("--changed and --since need a git repository, not an archive")
+	}
+	data, err := readArchiveInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(logOut, "Processing archive: %s\n", input)
+	var entries []archiveFile
+	switch format := archiveFormat(input); format {
+	case "zip":
+		entries, err = readZipArchive(input, data)
+	case "tar":
+		entries, err = readTarArchive(input, bytes.NewReader(data))
+	case "tar.gz":
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			defer gz.Close()
+			entries, err = readTarArchive(input, gz)
+		}
+	case "tar.zst":
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(bytes.NewReader(data)); err == nil {
+			defer zr.Close()
+			entries, err = readTarArchive(input, zr)
+		}
+	default:
+		err = fmt.Errorf("unsupported archive format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive %s: %w", input, err)
+	}
+	// The archive is walked like a directory, so every filter applies to it
+	files, err := walkSource(inputSource{fsys: newMemFS(input, entries), root: "."}, langData, nil)
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		files[i].Archive = input
+	}
+	return files, nil
+}
+
+// readArchiveInput loads the raw archive bytes from disk or over http(s),
+// refusing archives larger than --max-archive-size.
+func readArchiveInput(input string) ([]byte, error) {
+	if !isWebURL(input) {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, fmt.Errorf("error accessing path %s: %w", input, err)
+		}
+		if maxArchiveSize > 0 && info.Size() > maxArchiveSize {
+			return nil, fmt.Errorf("archive %s is larger than --max-archive-size (%d bytes)", input, maxArchiveSize)
+		}
+		return os.ReadFile(input)
+	}
+
+	fmt.Fprintf(logOut, "Downloading archive %s...\n", input)
+	res, err := http.Get(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", input, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: status %s", input, res.Status)
+	}
+	data, err := readLimited(res.Body, maxArchiveSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", input, err)
+	}
+	return data, nil
+}
+
+// errArchiveTooLarge is returned once an archive exceeds --max-archive-size.
+var errArchiveTooLarge = errors.New("archive is larger than --max-archive-size")
+
+// readLimited reads r to the end, failing with errArchiveTooLarge after limit
+// bytes. A limit of 0 or less reads everything.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errArchiveTooLarge
+	}
+	return data, nil
+}
+
+// archiveBudget tracks the extracted size and entry count of one archive so zip
+// bombs stop at --max-archive-size and --max-archive-entries.
+type archiveBudget struct {
+	remaining int64 // Bytes left, unlimited when maxArchiveSize is 0
+	entries   int
+}
+
+// newArchiveBudget starts a budget from the current limits.
+func newArchiveBudget() *archiveBudget {
+	return &archiveBudget{remaining: maxArchiveSize}
+}
+
+// addEntry counts one more entry against --max-archive-entries.
+func (b *archiveBudget) addEntry() error {
+	b.entries++
+	if maxArchiveEntries > 0 && b.entries > maxArchiveEntries {
+		return fmt.Errorf("archive has more than --max-archive-entries (%d) entries", maxArchiveEntries)
+	}
+	return nil
+}
+
+// read extracts one file, charging its size to the budget. The declared size of
+// an entry is not trusted; the content is read through a limit either way.
+func (b *archiveBudget) read(r io.Reader) ([]byte, error) {
+	if maxArchiveSize <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := readLimited(r, b.remaining)
+	if errors.Is(err, errArchiveTooLarge) {
+		return nil, fmt.Errorf("archive expands to more than --max-archive-size (%d bytes)", maxArchiveSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.remaining -= int64(len(data))
+	return data, nil
+}
+
+// readZipArchive reads the regular files of a zip archive.
+func readZipArchive(input string, data []byte) ([]archiveFile, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	budget := newArchiveBudget()
+	var files []archiveFile
+	for _, f := range zr.File {
+		if err := budget.addEntry(); err != nil {
+			return nil, err
+		}
+		mode := f.Mode()
+		if mode.IsDir() {
+			continue // Directories are implied by the files inside them
+		}
+		name, ok := archiveEntryPath(input, f.Name, mode)
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		content, err := budget.read(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		files = append(files, archiveFile{path: name, mode: mode, modTime: f.Modified, content: content})
+	}
+	return files, nil
+}
+
+// readTarArchive reads the regular files of an (already decompressed) tar stream.
+func readTarArchive(input string, r io.Reader) ([]archiveFile, error) {
+	tr := tar.NewReader(r)
+	budget := newArchiveBudget()
+	var files []archiveFile
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := budget.addEntry(); err != nil {
+			return nil, err
+		}
+		mode := hdr.FileInfo().Mode()
+		if hdr.Typeflag == tar.TypeDir || hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		name, ok := archiveEntryPath(input, hdr.Name, mode)
+		if !ok {
+			continue
+		}
+		content, err := budget.read(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		files = append(files, archiveFile{path: name, mode: mode, modTime: hdr.ModTime, content: content})
+	}
+	return files, nil
+}
+
+// archiveEntryPath validates an entry of a regular file and returns its cleaned
+// path. Links and special files are skipped, as are names that are absolute or
+// climb out of the archive root with "..", which could otherwise shadow real
+// paths in the output (zip-slip).
+func archiveEntryPath(input, name string, mode fs.FileMode) (string, bool) {
+	clean, ok := safeArchivePath(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Warning: skipping unsafe path %q in archive %s\n", name, input)
+		return "", false
+	}
+	if !mode.IsRegular() {
+		recordSkip(filepath.FromSlash(clean), "not a regular file")
+		return "", false
+	}
+	return clean, true
+}
+
+// safeArchivePath normalizes an archive entry name to a slash-separated path
+// relative to the archive root. It rejects absolute paths, Windows drive and UNC
+// paths and any name that resolves outside the root.
+func safeArchivePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", false
+	}
+	clean := path.Clean(name)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	return clean, true
+}
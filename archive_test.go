@@ -0,0 +1,98 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+)
+
+func TestSafeArchivePath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"main.go", "main.go", true},
+		{"src/main.go", "src/main.go", true},
+		{"./src//main.go", "src/main.go", true},
+		{"src/../main.go", "main.go", true},
+		{`src\main.go`, "src/main.go", true},
+		{"a/b/../../c", "c", true},
+		{"", "", false},
+		{".", "", false},
+		{"..", "", false},
+		{"../etc/passwd", "", false},
+		{"src/../../etc/passwd", "", false},
+		{`..\evil`, "", false},
+		{"/etc/passwd", "", false},
+		{`C:\Windows\system.ini`, "", false},
+		{"c:relative", "", false},
+		{`\\server\share\file`, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := safeArchivePath(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("safeArchivePath(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestArchiveFormat(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"project.zip", "zip"},
+		{"project.ZIP", "zip"},
+		{"project.tar", "tar"},
+		{"project.tar.gz", "tar.gz"},
+		{"project.tgz", "tar.gz"},
+		{"project.tar.zst", "tar.zst"},
+		{"project.tzst", "tar.zst"},
+		{"project.gz", ""},
+		{"main.go", ""},
+		{"https://example.com/archive/v1.tar.gz?download=1", "tar.gz"},
+		{"https://example.com/docs", ""},
+	}
+	for _, tt := range tests {
+		if got := archiveFormat(tt.input); got != tt.want {
+			t.Errorf("archiveFormat(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestReadTarArchiveSkipsUnsafeEntries(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	entries := []struct {
+		hdr     tar.Header
+		content string
+	}{
+		{tar.Header{Name: "src/", Typeflag: tar.TypeDir, Mode: 0o755}, ""},
+		{tar.Header{Name: "src/main.go", Typeflag: tar.TypeReg, Mode: 0o644}, "package main\n"},
+		{tar.Header{Name: "../escape.txt", Typeflag: tar.TypeReg, Mode: 0o644}, "outside"},
+		{tar.Header{Name: "/abs.txt", Typeflag: tar.TypeReg, Mode: 0o644}, "absolute"},
+		{tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd", Mode: 0o777}, ""},
+	}
+	for _, e := range entries {
+		hdr := e.hdr
+		hdr.Size = int64(len(e.content))
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := readTarArchive("test.tar", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].path != "src/main.go" || string(files[0].content) != "package main\n" {
+		t.Errorf("readTarArchive() = %+v, want only src/main.go", files)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// binarySkipReason marks binary files that are listed in the tree but not emitted.
+const binarySkipReason = "binary"
+
+// validBinaryModes are the accepted values of --include-binary ("" skips binaries).
+var validBinaryModes = []string{"hex", "base64", "placeholder"}
+
+// binarySniffLen is how much of a file is inspected, matching git's heuristic.
+const binarySniffLen = 8000
+
+// maxInvalidTextRatio is the share of invalid UTF-8 or control bytes above which content is binary.
+const maxInvalidTextRatio = 0.1
+
+// base64LineWidth wraps base64 output like MIME does so it stays line-oriented.
+const base64LineWidth = 76
+
+// isValidBinaryMode reports whether mode is an accepted --include-binary value.
+func isValidBinaryMode(mode string) bool {
+	return mode == "" || containsString(validBinaryModes, mode)
+}
+
+// isBinaryContent sniffs the start of content and reports whether it is binary:
+// it contains a NUL byte, http.DetectContentType recognises a binary signature,
+// or too many bytes are invalid UTF-8 or control characters. It also returns the
+// detected MIME type.
+func isBinaryContent(content []byte) (bool, string) {
+	sample := content
+	if len(sample) > binarySniffLen {
+		sample = sample[:binarySniffLen]
+	}
+	contentType := http.DetectContentType(sample)
+	if len(sample) == 0 {
+		return false, contentType
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true, contentType
+	}
+	if isBinaryContentType(contentType) {
+		return true, contentType
+	}
+
+	// Don't count a rune cut off by the sample limit as invalid
+	if len(content) > len(sample) {
+		for i := len(sample) - 1; i >= 0 && i >= len(sample)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(sample[i]) {
+				if !utf8.FullRune(sample[i:]) {
+					sample = sample[:i]
+				}
+				break
+			}
+		}
+	}
+
+	invalid := 0
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRune(sample[i:])
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		} else if r < 0x20 && r != '\n' && r != '\r' && r != '\t' && r != '\f' && r != '\b' && r != 0x1b {
+			invalid++
+		}
+		i += size
+	}
+	return float64(invalid)/float64(len(sample)) > maxInvalidTextRatio, contentType
+}
+
+// isBinaryContentType reports whether a sniffed MIME type is a binary format.
+// DetectContentType falls back to application/octet-stream for anything it
+// doesn't recognise, so that case is left to the UTF-8 check.
+func isBinaryContentType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return false
+	case mediaType == "application/octet-stream", mediaType == "application/json", mediaType == "application/postscript":
+		return false
+	case strings.HasSuffix(mediaType, "+xml"), strings.HasSuffix(mediaType, "/xml"):
+		return false
+	}
+	return true
+}
+
+// encodeBinaryContent renders binary content according to --include-binary.
+func encodeBinaryContent(content []byte, size int64, contentType string) []byte {
+	switch includeBinary {
+	case "hex":
+		return []byte(hex.Dump(content))
+	case "base64":
+		encoded := base64.StdEncoding.EncodeToString(content)
+		var b strings.Builder
+		for len(encoded) > base64LineWidth {
+			b.WriteString(encoded[:base64LineWidth])
+			b.WriteByte('\n')
+			encoded = encoded[base64LineWidth:]
+		}
+		b.WriteString(encoded)
+		b.WriteByte('\n')
+		return []byte(b.String())
+	default: // placeholder
+		return []byte(fmt.Sprintf("[binary file: %d bytes, %s]\n", size, contentType))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsBinaryContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"empty", "", false},
+		{"ascii text", "package main\n\nfunc main() {}\n", false},
+		{"utf-8 text", "héllo wörld — ✓ 日本語\n", false},
+		{"ansi escapes and tabs", "\x1b[31mred\x1b[0m\tok\r\n", false},
+		{"json", `{"key": [1, 2, 3]}`, false},
+		{"svg", `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`, false},
+		{"nul byte", "text\x00more text", true},
+		{"png signature", "\x89PNG\r\n\x1a\n" + strings.Repeat("a", 100), true},
+		{"pdf signature", "%PDF-1.7\n" + strings.Repeat("a", 100), true},
+		{"gzip signature", "\x1f\x8b\x08" + strings.Repeat("a", 100), true},
+		{"latin-1 text", strings.Repeat("caf\xe9 ", 20), true},
+		{"control bytes", strings.Repeat("a\x01\x02\x03", 20), true},
+		{"few invalid bytes", strings.Repeat("a", 100) + "\xff", false},
+		{"nul after sniff window", strings.Repeat("a", binarySniffLen) + "\x00", false},
+		{"rune cut by sniff window", strings.Repeat("a", binarySniffLen-1) + "é" + strings.Repeat("b", 10), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, contentType := isBinaryContent([]byte(tt.content)); got != tt.want {
+				t.Errorf("isBinaryContent() = %v (%s), want %v", got, contentType, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeBinaryContent(t *testing.T) {
+	content := []byte("\x00\x01binary\xff")
+	tests := []struct {
+		mode, want string
+	}{
+		{"hex", "00000000  00 01 62 69 6e 61 72 79  ff                       |..binary.|\n"},
+		{"base64", "AAFiaW5hcnn/\n"},
+		{"placeholder", "[binary file: 9 bytes, application/octet-stream]\n"},
+	}
+	for _, tt := range tests {
+		setFlag(t, &includeBinary, tt.mode)
+		if got := string(encodeBinaryContent(content, int64(len(content)), "application/octet-stream")); got != tt.want {
+			t.Errorf("--include-binary %s: got %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+
+	// base64 is wrapped at 76 columns
+	setFlag(t, &includeBinary, "base64")
+	encoded := string(encodeBinaryContent(make([]byte, 100), 100, "application/octet-stream"))
+	for _, line := range strings.Split(strings.TrimSuffix(encoded, "\n"), "\n") {
+		if len(line) > base64LineWidth {
+			t.Errorf("base64 line of %d characters: %q", len(line), line)
+		}
+	}
+}
+
+func TestIsValidBinaryMode(t *testing.T) {
+	for mode, want := range map[string]bool{"": true, "hex": true, "base64": true, "placeholder": true, "raw": false} {
+		if got := isValidBinaryMode(mode); got != want {
+			t.Errorf("isValidBinaryMode(%q) = %v, want %v", mode, got, want)
+		}
+	}
+}
+
+func TestProcessContentBinary(t *testing.T) {
+	content := []byte("\x89PNG\r\n\x1a\n\x00\x00")
+	file := FileInfo{Path: "logo.png", Size: int64(len(content))}
+
+	setFlag(t, &includeBinary, "")
+	skipped, emitted := processContent(file, content, byteTokenizer{})
+	if !skipped.Binary || skipped.SkipReason != binarySkipReason || emitted != nil {
+		t.Errorf("binary file without --include-binary: Binary %v, SkipReason %q, content %q", skipped.Binary, skipped.SkipReason, emitted)
+	}
+
+	setFlag(t, &includeBinary, "placeholder")
+	included, emitted := processContent(file, content, byteTokenizer{})
+	if want := "[binary file: 10 bytes, image/png]\n"; !included.Binary || included.SkipReason != "" || string(emitted) != want {
+		t.Errorf("binary file with --include-binary placeholder: Binary %v, SkipReason %q, content %q", included.Binary, included.SkipReason, emitted)
+	}
+	if included.TokenCount != len(emitted) {
+		t.Errorf("TokenCount = %d, want the encoded content counted", included.TokenCount)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// budgetSkipReason marks files dropped by --max-tokens in the tree and JSON output.
+const budgetSkipReason = "omitted: token budget"
+
+// validBudgetStrategies lists the values accepted by --budget-strategy.
+var validBudgetStrategies = []string{"smallest", "priority", "recent"}
+
+// applyTokenBudget selects a subset of files whose records, plus the tree and summary
+// around them, fit within limit once rendered by r in the selected output format.
+// base holds the parts of the summary known before the selection (git sources,
+// repository history). Files that don't fit keep their place in the tree but get
+// budgetSkipReason. It returns the omitted paths and their token total.
+func applyTokenBudget(files []FileInfo, limit int, strategy string, priorityPatterns []string, base Summary, tk Tokenizer, r outputRenderer) ([]string, int) {
+	// Candidates are the files with content to emit, ordered by the chosen strategy
+	var candidates []int
+	for i, file := range files {
+		if !file.IsDir && file.Error == nil && file.SkipReason == "" {
+			candidates = append(candidates, i)
+		}
+	}
+	sortBudgetCandidates(files, candidates, strategy, priorityPatterns)
+
+	// Per-file cost is its record as the output format renders it.
+	// Each omitted file also costs a line in the summary.
+	costs := make(map[int]int, len(candidates))
+	omitLineCosts := make(map[int]int, len(candidates))
+	for _, i := range candidates {
+		costs[i] = r.recordTokens(files[i], tk)
+		omitLineCosts[i] = r.omitLineTokens(files[i].Path, tk)
+	}
+
+	// The rest of the output is rendered with every candidate omitted, which draws the
+	// tree at its largest, and a summary with the statistics of every candidate.
+	summary := base
+	summarizeFiles(&summary, files, tk)
+	summary.TokenBudget = limit
+	summary.OmittedFiles = []string{""}
+	summary.OmittedTokens = summary.TotalTokens
+	for _, i := range candidates {
+		files[i].SkipReason = budgetSkipReason
+	}
+	var rendered strings.Builder
+	if err := r.write(&rendered, append([]FileInfo(nil), files...), summary); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not measure output for the token budget: %v\n", err)
+	}
+	overhead := tk.CountTokens(rendered.String())
+
+	// Summary lines for omitted files depend on the selection, so reserve room for them
+	// and reselect until the reservation covers what was actually omitted.
+	reserve := 0
+	for {
+		available := limit - overhead - reserve
+		used, omitCost := 0, 0
+		for _, i := range candidates {
+			if used+costs[i] <= available {
+				files[i].SkipReason = ""
+				used += costs[i]
+			} else {
+				files[i].SkipReason = budgetSkipReason
+				omitCost += omitLineCosts[i]
+			}
+		}
+		if omitCost <= reserve {
+			break
+		}
+		reserve = omitCost
+	}
+
+	var omitted []string
+	omittedTokens := 0
+	for _, i := range candidates {
+		if files[i].SkipReason == budgetSkipReason {
+			omitted = append(omitted, files[i].Path)
+			omittedTokens += files[i].TokenCount
+		}
+	}
+	sort.Strings(omitted)
+	return omitted, omittedTokens
+}
+
+// sortBudgetCandidates orders candidate indices so the most wanted files come first.
+func sortBudgetCandidates(files []FileInfo, candidates []int, strategy string, priorityPatterns []string) {
+	less := func(a, b FileInfo) bool { // smallest-first, the default and the tie breaker
+		if a.TokenCount != b.TokenCount {
+			return a.TokenCount < b.TokenCount
+		}
+		return a.Path < b.Path
+	}
+
+	switch strategy {
+	case "priority":
+		smallest := less
+		less = func(a, b FileInfo) bool {
+			pa, pb := priorityRank(a.Path, priorityPatterns), priorityRank(b.Path, priorityPatterns)
+			if pa != pb {
+				return pa < pb
+			}
+			return smallest(a, b)
+		}
+	case "recent":
+		smallest := less
+		less = func(a, b FileInfo) bool {
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.After(b.ModTime)
+			}
+			return smallest(a, b)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return less(files[candidates[i]], files[candidates[j]])
+	})
+}
+
+// priorityRank returns the index of the first pattern matching path, or
+// len(patterns) if none match, so earlier patterns win.
+func priorityRank(path string, patterns []string) int {
+	slashPath := filepath.ToSlash(path)
+	for rank, pattern := range patterns {
+		if matched, _ := matchesAnyPattern(slashPath, []string{pattern}, false); matched {
+			return rank
+		}
+	}
+	return len(patterns)
+}
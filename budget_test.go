@@ -0,0 +1,186 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSortBudgetCandidates(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []FileInfo{
+		{Path: "a.go", TokenCount: 30, ModTime: now},
+		{Path: "b_test.go", TokenCount: 10, ModTime: now.Add(2 * time.Hour)},
+		{Path: "docs/c.md", TokenCount: 20, ModTime: now.Add(time.Hour)},
+		{Path: "main.go", TokenCount: 10, ModTime: now.Add(time.Hour)},
+	}
+	tests := []struct {
+		strategy string
+		patterns []string
+		want     []string
+	}{
+		{"smallest", nil, []string{"b_test.go", "main.go", "docs/c.md", "a.go"}},
+		{"priority", []string{"*.md", "a.go"}, []string{"docs/c.md", "a.go", "b_test.go", "main.go"}},
+		{"priority", []string{"a.go", "*.md"}, []string{"a.go", "docs/c.md", "b_test.go", "main.go"}},
+		{"priority", []string{"docs/**"}, []string{"docs/c.md", "b_test.go", "main.go", "a.go"}},
+		{"priority", nil, []string{"b_test.go", "main.go", "docs/c.md", "a.go"}},
+		{"recent", nil, []string{"b_test.go", "main.go", "docs/c.md", "a.go"}},
+	}
+	for _, tt := range tests {
+		candidates := []int{0, 1, 2, 3}
+		sortBudgetCandidates(files, candidates, tt.strategy, tt.patterns)
+		var got []string
+		for _, i := range candidates {
+			got = append(got, files[i].Path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %v: order = %v, want %v", tt.strategy, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestPriorityRank(t *testing.T) {
+	patterns := []string{"cmd/**", "*.go"}
+	for path, want := range map[string]int{"cmd/iris/main.go": 0, "util.go": 1, "README.md": 2} {
+		if got := priorityRank(path, patterns); got != want {
+			t.Errorf("priorityRank(%q) = %d, want %d", path, got, want)
+		}
+	}
+}
+
+// budgetTestFiles writes files of increasing size and processes them for the
+// selected output format, counting one token per byte.
+func budgetTestFiles(t *testing.T) ([]FileInfo, *contentStore) {
+	t.Helper()
+	dir := t.TempDir()
+	var files []FileInfo
+	for i, name := range []string{"a.txt", "b.txt", "docs/c.md", "d.go", "e.go", "big.txt"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		writeTestFile(t, path, strings.Repeat("line <"+name+"> & more\n", 5*(i+1)))
+		source, sourcePath := fileSource(path)
+		files = append(files, FileInfo{Path: path, Size: int64(len(name)), Source: source, SourcePath: sourcePath})
+	}
+	processed, store, err := processFiles(files, byteTokenizer{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return processed, store
+}
+
+// renderWithBudget applies a token budget to files as main does and renders the output.
+func renderWithBudget(t *testing.T, files []FileInfo, store *contentStore, limit int, strategy string, priorities []string) (string, []string) {
+	t.Helper()
+	tmpl, err := loadOutputTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := byteTokenizer{}
+	renderer := outputRenderer{template: tmpl, inputs: []string{filepath.Dir(files[0].Path)}, contents: store}
+	summary := Summary{}
+	var omitted []string
+	if limit > 0 {
+		var omittedTokens int
+		omitted, omittedTokens = applyTokenBudget(files, limit, strategy, priorities, summary, tk, renderer)
+		summary.TokenBudget = limit
+		summary.OmittedFiles = omitted
+		summary.OmittedTokens = omittedTokens
+	}
+	summarizeFiles(&summary, files, tk)
+	var out strings.Builder
+	if err := renderer.write(&out, files, summary); err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), omitted
+}
+
+func TestApplyTokenBudgetStaysWithinLimit(t *testing.T) {
+	formats := []struct{ result, output string }{
+		{"text", "both"},
+		{"text", "files"},
+		{"text", "xml"},
+		{"text", "markdown"},
+		{"json", "both"},
+		{"jsonl", "both"},
+	}
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	setFlag(t, &disableTokens, false)
+	setFlag(t, &maxTokens, 1) // Processing counts output tokens only under a budget
+	for _, format := range formats {
+		t.Run(format.result+"/"+format.output, func(t *testing.T) {
+			setFlag(t, &resultFormat, format.result)
+			setFlag(t, &outputFormat, format.output)
+
+			files, store := budgetTestFiles(t)
+			full, _ := renderWithBudget(t, files, store, 0, "", nil)
+			for _, limit := range []int{len(full) * 3 / 4, len(full) * 2 / 3} {
+				files, store := budgetTestFiles(t)
+				out, omitted := renderWithBudget(t, files, store, limit, "smallest", nil)
+				if len(out) > limit {
+					t.Errorf("limit %d: output has %d tokens", limit, len(out))
+				}
+				if len(omitted) == 0 || len(omitted) == len(files) {
+					t.Errorf("limit %d: omitted %d of %d files, want some", limit, len(omitted), len(files))
+				}
+				if !strings.Contains(out, budgetSkipReason) && format.output != "files" {
+					t.Errorf("limit %d: omitted files are not marked in the output", limit)
+				}
+			}
+
+			// A budget with room for everything omits nothing
+			files, store = budgetTestFiles(t)
+			if out, omitted := renderWithBudget(t, files, store, 2*len(full), "smallest", nil); len(omitted) != 0 || len(out) > 2*len(full) {
+				t.Errorf("generous budget omitted %v (%d tokens)", omitted, len(out))
+			}
+		})
+	}
+}
+
+func TestApplyTokenBudgetStrategies(t *testing.T) {
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	setFlag(t, &disableTokens, false)
+	setFlag(t, &maxTokens, 1) // Processing counts output tokens only under a budget
+	setFlag(t, &resultFormat, "text")
+	setFlag(t, &outputFormat, "both")
+
+	files, store := budgetTestFiles(t)
+	full, _ := renderWithBudget(t, files, store, 0, "", nil)
+	limit := len(full) / 2
+	kept := func(files []FileInfo) []string {
+		var names []string
+		for _, file := range files {
+			if file.SkipReason == "" {
+				names = append(names, filepath.Base(file.Path))
+			}
+		}
+		return names
+	}
+
+	// smallest keeps the smallest files
+	files, store = budgetTestFiles(t)
+	renderWithBudget(t, files, store, limit, "smallest", nil)
+	if got := kept(files); len(got) == 0 || got[0] != "a.txt" || containsString(got, "big.txt") {
+		t.Errorf("smallest kept %v", got)
+	}
+
+	// priority keeps the prioritised big file, with smaller files filling the rest
+	files, store = budgetTestFiles(t)
+	renderWithBudget(t, files, store, limit, "priority", []string{"big.txt"})
+	if got := kept(files); !containsString(got, "big.txt") {
+		t.Errorf("priority kept %v, want big.txt among them", got)
+	}
+
+	// recent keeps the most recently modified files
+	files, store = budgetTestFiles(t)
+	for i := range files {
+		files[i].ModTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	files[4].ModTime = files[4].ModTime.Add(time.Hour) // e.go
+	renderWithBudget(t, files, store, limit, "recent", nil)
+	if got := kept(files); !containsString(got, "e.go") || containsString(got, "big.txt") {
+		t.Errorf("recent kept %v, want e.go and not big.txt", got)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/spf13/cobra"
+)
+
+// cacheLastUsedFile is touched inside a cached repository every time it is used.
+const cacheLastUsedFile = "iris-last-used"
+
+// defaultCachePruneAge is how long an unused repository stays cached for `iris cache prune`.
+const defaultCachePruneAge = 30 * 24 * time.Hour
+
+// cacheNamePattern matches the characters replaced when turning a URL into a directory name.
+var cacheNamePattern = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// cacheDir returns the directory holding cached repositories:
+// $XDG_CACHE_HOME/iris/repos, or the platform's user cache directory.
+func cacheDir() (string, error) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		var err error
+		base, err = os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find a cache directory: %w", err)
+		}
+	}
+	return filepath.Join(base, "iris", "repos"), nil
+}
+
+// cachePathFor returns the cache location for a repository URL: a readable name
+// derived from the URL plus a hash, so different URLs never collide.
+func cachePathFor(url string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(url))
+	name := strings.Trim(cacheNamePattern.ReplaceAllString(strings.TrimSuffix(url, ".git"), "_"), "_.")
+	if len(name) > 64 {
+		name = name[len(name)-64:]
+	}
+	return filepath.Join(dir, name+"-"+hex.EncodeToString(sum[:])[:12]), nil
+}
+
+// ensureCachedRepo returns the path of an up to date bare mirror of url, cloning
+// it on first use and fetching only new objects afterwards.
+func ensureCachedRepo(url string) (string, error) {
+	path, err := cachePathFor(url)
+	if err != nil {
+		return "", err
+	}
+
+	repo, err := git.PlainOpen(path)
+	switch {
+	case err == nil:
+		fmt.Fprintf(logOut, "Updating cached repository '%s'...\n", url)
+		err = repo.Fetch(&git.FetchOptions{
+			RemoteName: "origin",
+			RefSpecs:   []config.RefSpec{"+refs/*:refs/*"},
+			Progress:   logOut,
+			Tags:       git.AllTags,
+			Force:      true,
+			Prune:      true,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return "", fmt.Errorf("failed to update cached repository '%s': %w", url, err)
+		}
+	case errors.Is(err, git.ErrRepositoryNotExists):
+		fmt.Fprintf(logOut, "Caching Git repository '%s' in '%s'...\n", url, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		_, err = git.PlainClone(path, true, &git.CloneOptions{
+			URL:      url,
+			Mirror:   true, // All branches and tags, so any ref can be checked out later
+			Progress: logOut,
+		})
+		if err != nil {
+			_ = os.RemoveAll(path)
+			return "", fmt.Errorf("failed to cache repository '%s': %w", url, err)
+		}
+	default:
+		return "", fmt.Errorf("failed to open cached repository %s: %w", path, err)
+	}
+
+	now := time.Now()
+	if err := os.WriteFile(filepath.Join(path, cacheLastUsedFile), []byte(now.Format(time.RFC3339)+"\n"), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record cache use for %s: %v\n", path, err)
+	}
+	return path, nil
+}
+
+// cachedRepo describes one entry of the repository cache.
+type cachedRepo struct {
+	Path     string
+	URL      string
+	Size     int64
+	LastUsed time.Time
+}
+
+// listCachedRepos returns the cached repositories, most recently used first.
+func listCachedRepos() ([]cachedRepo, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []cachedRepo
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		entry := cachedRepo{Path: filepath.Join(dir, entry.Name())}
+		if repo, err := git.PlainOpen(entry.Path); err == nil {
+			if remote, err := repo.Remote("origin"); err == nil && len(remote.Config().URLs) > 0 {
+				entry.URL = remote.Config().URLs[0]
+			}
+		}
+		if info, err := os.Stat(filepath.Join(entry.Path, cacheLastUsedFile)); err == nil {
+			entry.LastUsed = info.ModTime()
+		}
+		entry.Size = dirSize(entry.Path)
+		repos = append(repos, entry)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].LastUsed.After(repos[j].LastUsed)
+	})
+	return repos, nil
+}
+
+// dirSize returns the total size of the regular files below path.
+func dirSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// cachePruneAge is the --older-than value of `iris cache prune`.
+var cachePruneAge time.Duration
+
+// cacheCmd groups the repository cache subcommands.
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local cache of cloned Git repositories",
+	Long: `Git URL inputs are cloned once into a local cache ($XDG_CACHE_HOME/iris/repos)
+and only fetched incrementally on later runs. Use --no-cache to bypass it.
+
+Because "cache" is a subcommand, a local directory named cache must be passed
+as ./cache (or any other path that is not exactly "cache").`,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cached repositories",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repos, err := listCachedRepos()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading cache: %v\n", err)
+			os.Exit(1)
+		}
+		if len(repos) == 0 {
+			fmt.Println("No cached repositories.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "URL\tSIZE\tLAST USED\tPATH")
+		for _, repo := range repos {
+			lastUsed := "unknown"
+			if !repo.LastUsed.IsZero() {
+				lastUsed = repo.LastUsed.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%d bytes\t%s\t%s\n", repo.URL, repo.Size, lastUsed, repo.Path)
+		}
+		w.Flush()
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached repositories that have not been used recently",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repos, err := listCachedRepos()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading cache: %v\n", err)
+			os.Exit(1)
+		}
+		cutoff := time.Now().Add(-cachePruneAge)
+		removed := 0
+		for _, repo := range repos {
+			if repo.LastUsed.After(cutoff) {
+				continue
+			}
+			if err := os.RemoveAll(repo.Path); err != nil {
+				fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", repo.Path, err)
+				continue
+			}
+			fmt.Printf("Removed %s (%s)\n", repo.URL, repo.Path)
+			removed++
+		}
+		fmt.Printf("Pruned %d cached repositories unused for %s.\n", removed, cachePruneAge)
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached repositories",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := cacheDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error clearing cache %s: %v\n", dir, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cleared %s\n", dir)
+	},
+}
+
+func init() {
+	cachePruneCmd.Flags().DurationVar(&cachePruneAge, "older-than", defaultCachePruneAge, "Remove repositories not used for this long")
+	cacheCmd.AddCommand(cacheListCmd, cachePruneCmd, cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/cobra"
+)
+
+// useTestCache points the repository cache at a temporary directory and silences
+// progress output.
+func useTestCache(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	setFlag(t, &noCache, false)
+	return filepath.Join(dir, "iris", "repos")
+}
+
+func TestCachedRepoRoundTrip(t *testing.T) {
+	cacheRoot := useTestCache(t)
+	remote := newTestRemote(t)
+	first := remote.commit(t, "README.md", "# First\n")
+	remote.tag(t, "v1")
+
+	path, err := ensureCachedRepo(remote.url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != cacheRoot {
+		t.Errorf("cached repository %s is not in %s", path, cacheRoot)
+	}
+	cached, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.CommitObject(plumbing.NewHash(first)); err != nil {
+		t.Errorf("first commit missing from the cache: %v", err)
+	}
+
+	// A later use fetches new commits into the same mirror
+	second := remote.commit(t, "README.md", "# Second\n")
+	again, err := ensureCachedRepo(remote.url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != path {
+		t.Errorf("ensureCachedRepo() = %s on reuse, want %s", again, path)
+	}
+	cached, err = git.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.CommitObject(plumbing.NewHash(second)); err != nil {
+		t.Errorf("second commit not fetched into the cache: %v", err)
+	}
+
+	// Clones are made from the cache, at the default branch or any ref
+	for _, tt := range []struct {
+		ref, sha, content string
+	}{
+		{"", second, "# Second\n"},
+		{"v1", first, "# First\n"},
+		{first, first, "# First\n"},
+	} {
+		dir, sha, err := cloneGitRepo(remote.url, tt.ref, "")
+		if err != nil {
+			t.Fatalf("cloneGitRepo(ref=%q): %v", tt.ref, err)
+		}
+		defer os.RemoveAll(dir)
+		content, err := os.ReadFile(filepath.Join(dir, "README.md"))
+		if err != nil || string(content) != tt.content || sha != tt.sha {
+			t.Errorf("cloneGitRepo(ref=%q) = %s with %q, %v; want %s with %q", tt.ref, sha, content, err, tt.sha, tt.content)
+		}
+	}
+
+	repos, err := listCachedRepos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].Path != path || repos[0].URL != remote.url || repos[0].Size == 0 || repos[0].LastUsed.IsZero() {
+		t.Errorf("listCachedRepos() = %+v", repos)
+	}
+}
+
+func TestListCachedReposEmpty(t *testing.T) {
+	useTestCache(t)
+	repos, err := listCachedRepos()
+	if err != nil || len(repos) != 0 {
+		t.Errorf("listCachedRepos() = %+v, %v; want none", repos, err)
+	}
+}
+
+func TestCachePathFor(t *testing.T) {
+	cacheRoot := useTestCache(t)
+	a, err := cachePathFor("https://github.com/org/repo.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := cachePathFor("https://github.com/org/repo.git")
+	c, _ := cachePathFor("https://gitlab.com/org/repo.git")
+	if a != b {
+		t.Errorf("cachePathFor() is not stable: %s != %s", a, b)
+	}
+	if a == c {
+		t.Errorf("cachePathFor() collides for different URLs: %s", a)
+	}
+	name := filepath.Base(a)
+	if filepath.Dir(a) != cacheRoot || !strings.HasPrefix(name, "https_github.com_org_repo-") {
+		t.Errorf("cachePathFor() = %s", a)
+	}
+
+	long, _ := cachePathFor("https://example.com/" + strings.Repeat("x", 200) + ".git")
+	if n := len(filepath.Base(long)); n > 64+1+12 {
+		t.Errorf("cachePathFor() name has %d characters, want at most 77", n)
+	}
+}
+
+func TestCacheSubcommandLeavesPathsToRoot(t *testing.T) {
+	for args, want := range map[string]*cobra.Command{
+		"cache":      cacheCmd,
+		"cache list": cacheListCmd,
+		"./cache":    rootCmd,
+		"src cache":  rootCmd,
+		"cache/sub":  rootCmd,
+	} {
+		cmd, _, err := rootCmd.Find(strings.Fields(args))
+		if err != nil {
+			t.Fatalf("Find(%q): %v", args, err)
+		}
+		if cmd != want {
+			t.Errorf("Find(%q) = %s, want %s", args, cmd.Name(), want.Name())
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// deletedSkipReason marks deleted files, which are listed in the tree but have no content.
+const deletedSkipReason = "deleted"
+
+// changeSelectionEnabled reports whether --changed, --since or --diff limits the output to changed files.
+func changeSelectionEnabled() bool {
+	return changedOnly || changeBaseRef() != ""
+}
+
+// changeBaseRef returns the ref changes are selected against: --diff's ref, else --since's.
+func changeBaseRef() string {
+	if diffRef != "" {
+		return diffRef
+	}
+	return sinceRef
+}
+
+// changeSet holds the change status (A, M, D or R) of every touched file in a repository.
+type changeSet struct {
+	root        string            // Absolute repository root
+	statuses    map[string]string // Absolute path -> status
+	dirs        map[string]bool   // Absolute directories containing at least one change
+	renamedFrom map[string]string // Repository path -> path before the rename
+	diffTree    *object.Tree      // Tree at the --diff ref, nil without --diff
+	objects     *gitObjects       // Reads the ref side of diffs, nil without --diff
+}
+
+// loadChangeSet opens the git repository containing path and collects the files
+// changed in the working tree and index against HEAD (--changed) and/or since
+// the --since/--diff ref (which also includes uncommitted changes).
+func loadChangeSet(path string) (*changeSet, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, fmt.Errorf("--changed/--since need a git repository at %s: %w", path, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("--changed/--since need a repository with a working tree: %w", err)
+	}
+	root, err := filepath.Abs(worktree.Filesystem.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]string) // Slash-separated path relative to root -> status
+	set := &changeSet{root: root, statuses: make(map[string]string), dirs: make(map[string]bool), renamedFrom: make(map[string]string)}
+
+	if ref := changeBaseRef(); ref != "" {
+		fromTree, err := treeAt(repo, plumbing.Revision(ref))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", ref, err)
+		}
+		committed, err := committedChanges(repo, fromTree, ref, set.renamedFrom)
+		if err != nil {
+			return nil, err
+		}
+		for rel, status := range committed {
+			statuses[rel] = status
+		}
+		if diffRef != "" {
+			set.diffTree = fromTree
+			set.objects = &gitObjects{storer: repo.Storer}
+		}
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read working tree status: %w", err)
+	}
+	worktreeCodes := make(map[string]string)
+	for rel, fileStatus := range status {
+		if code := worktreeChangeCode(fileStatus); code != "" {
+			worktreeCodes[rel] = code
+		}
+	}
+	renames, err := worktreeRenames(repo, root, worktreeCodes)
+	if err != nil {
+		return nil, err
+	}
+	for rel, old := range renames {
+		delete(worktreeCodes, old)
+		worktreeCodes[rel] = "R"
+		switch statuses[old] {
+		case "A":
+			worktreeCodes[rel] = "A" // Added since the ref, then moved
+		case "R":
+			set.renamedFrom[rel] = set.renamedFrom[old]
+		default:
+			set.renamedFrom[rel] = old
+		}
+		delete(statuses, old)
+	}
+	for rel, code := range worktreeCodes {
+		if previous, ok := statuses[rel]; ok && code != "D" && code != "R" {
+			code = previous // Keep "added since ref" over "modified since HEAD"
+		}
+		statuses[rel] = code
+	}
+
+	for rel, code := range statuses {
+		abs := filepath.Join(root, filepath.FromSlash(rel))
+		set.statuses[abs] = code
+		for dir := filepath.Dir(abs); dir != root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			set.dirs[dir] = true
+		}
+	}
+	return set, nil
+}
+
+// committedChanges diffs fromTree (the tree at ref) against HEAD, detecting renames.
+// The original path of each renamed file is stored in renamedFrom.
+func committedChanges(repo *git.Repository, fromTree *object.Tree, ref string, renamedFrom map[string]string) (map[string]string, error) {
+	toTree, err := treeAt(repo, plumbing.Revision(plumbing.HEAD))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	changes, err := object.DiffTreeWithOptions(context.Background(), fromTree, toTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s..HEAD: %w", ref, err)
+	}
+
+	result := make(map[string]string)
+	for _, change := range changes {
+		from, to := change.From.Name, change.To.Name
+		switch {
+		case from == "":
+			result[to] = "A"
+		case to == "":
+			result[from] = "D"
+		case from != to:
+			result[to] = "R"
+			renamedFrom[to] = from
+		default:
+			result[to] = "M"
+		}
+	}
+	return result, nil
+}
+
+// worktreeRenames pairs deleted and added files with identical content, since
+// go-git's Status never reports renames. Added files are compared by their staged
+// blob, or their content on disk when untracked. It returns new path -> old path.
+func worktreeRenames(repo *git.Repository, root string, codes map[string]string) (map[string]string, error) {
+	var deleted, added []string
+	for rel, code := range codes {
+		switch code {
+		case "D":
+			deleted = append(deleted, rel)
+		case "A":
+			added = append(added, rel)
+		}
+	}
+	if len(deleted) == 0 || len(added) == 0 {
+		return nil, nil
+	}
+	head, err := treeAt(repo, plumbing.Revision(plumbing.HEAD))
+	if err != nil {
+		return nil, nil // Nothing was committed yet, so nothing can have moved
+	}
+	index, err := repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the git index: %w", err)
+	}
+
+	sort.Strings(deleted)
+	deletedByHash := make(map[plumbing.Hash][]string)
+	deletedSizes := make(map[int64]bool) // Untracked files of other sizes are not read
+	for _, rel := range deleted {
+		entry, err := head.FindEntry(rel)
+		if err != nil {
+			continue
+		}
+		deletedByHash[entry.Hash] = append(deletedByHash[entry.Hash], rel)
+		if blob, err := repo.BlobObject(entry.Hash); err == nil {
+			deletedSizes[blob.Size] = true
+		}
+	}
+
+	sort.Strings(added)
+	renames := make(map[string]string)
+	for _, rel := range added {
+		var hash plumbing.Hash
+		if entry, err := index.Entry(rel); err == nil {
+			hash = entry.Hash
+		} else {
+			path := filepath.Join(root, filepath.FromSlash(rel))
+			if info, err := os.Stat(path); err != nil || !deletedSizes[info.Size()] {
+				continue
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			hash = plumbing.ComputeHash(plumbing.BlobObject, content)
+		}
+		if olds := deletedByHash[hash]; len(olds) > 0 {
+			renames[rel] = olds[0]
+			deletedByHash[hash] = olds[1:]
+		}
+	}
+	return renames, nil
+}
+
+// treeAt resolves a revision to the tree of its commit.
+func treeAt(repo *git.Repository, rev plumbing.Revision) (*object.Tree, error) {
+	hash, err := repo.ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// worktreeChangeCode maps a go-git file status to A/M/D/R, preferring the staged
+// change. Untracked files count as added. It returns "" for unchanged files.
+func worktreeChangeCode(status *git.FileStatus) string {
+	code := status.Staging
+	if code == git.Unmodified {
+		code = status.Worktree
+	}
+	switch code {
+	case git.Added, git.Untracked, git.Copied:
+		return "A"
+	case git.Modified, git.UpdatedButUnmerged:
+		return "M"
+	case git.Deleted:
+		return "D"
+	case git.Renamed:
+		return "R"
+	}
+	return ""
+}
+
+// status returns the change status of path, if it changed.
+func (c *changeSet) status(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	code, ok := c.statuses[abs]
+	return code, ok
+}
+
+// repoPath returns path relative to the repository root, slash-separated.
+func (c *changeSet) repoPath(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(c.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// containsDir reports whether any change lies below the directory path.
+func (c *changeSet) containsDir(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return true // Walk it rather than miss changes
+	}
+	return c.dirs[abs]
+}
+
+// deletedUnder returns the deleted files below root as paths joined onto root
+// (matching the paths filepath.WalkDir reports), sorted.
+func (c *changeSet) deletedUnder(root string) []string {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for abs, code := range c.statuses {
+		if code != "D" {
+			continue
+		}
+		rel, err := filepath.Rel(absRoot, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		paths = append(paths, filepath.Join(root, rel))
+	}
+	sort.Strings(paths)
+	return paths
+}
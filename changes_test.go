@@ -0,0 +1,276 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testRepo is a git repository with a working tree in a temporary directory.
+type testRepo struct {
+	dir      string
+	repo     *git.Repository
+	worktree *git.Worktree
+	when     time.Time // Commit time of the next commit, advanced by each commit
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testRepo{dir: dir, repo: repo, worktree: worktree, when: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+// path returns the absolute path of a slash-separated repository path.
+func (r *testRepo) path(name string) string {
+	return filepath.Join(r.dir, filepath.FromSlash(name))
+}
+
+func (r *testRepo) write(t *testing.T, name, content string) {
+	t.Helper()
+	writeTestFile(t, r.path(name), content)
+}
+
+func (r *testRepo) remove(t *testing.T, name string) {
+	t.Helper()
+	if err := os.Remove(r.path(name)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commit stages every change in the working tree and commits it as "Test".
+func (r *testRepo) commit(t *testing.T, message string) string {
+	t.Helper()
+	return r.commitAs(t, "Test", message)
+}
+
+// commitAs stages every change in the working tree and commits it as author.
+// It returns the commit SHA.
+func (r *testRepo) commitAs(t *testing.T, author, message string) string {
+	t.Helper()
+	if err := r.worktree.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: author, Email: author + "@example.com", When: r.when}
+	r.when = r.when.Add(time.Hour)
+	hash, err := r.worktree.Commit(message, &git.CommitOptions{Author: signature, Committer: signature})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash.String()
+}
+
+// changeStatuses returns the statuses of a change set by repository path.
+func changeStatuses(t *testing.T, set *changeSet) map[string]string {
+	t.Helper()
+	statuses := make(map[string]string)
+	for abs, code := range set.statuses {
+		rel, ok := set.repoPath(abs)
+		if !ok {
+			t.Fatalf("change %s is outside the repository %s", abs, set.root)
+		}
+		statuses[rel] = code
+	}
+	return statuses
+}
+
+func TestLoadChangeSetChanged(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.write(t, "edited.go", "package main\n")
+	repo.write(t, "removed.go", "package removed\n")
+	repo.write(t, "old.go", "package moved\n")
+	repo.write(t, "staged.go", "package staged\n")
+	repo.write(t, "same.go", "package same\n")
+	repo.write(t, "pkg/deep.go", "package pkg\n")
+	repo.commit(t, "Initial commit")
+
+	repo.write(t, "edited.go", "package main\n\nfunc main() {}\n")
+	repo.remove(t, "removed.go")
+	repo.write(t, "pkg/new.go", "package pkg\n\nvar x = 1\n")
+	// An unstaged move shows up as a deletion and an untracked file
+	repo.remove(t, "old.go")
+	repo.write(t, "moved.go", "package moved\n")
+	// A staged move shows up as a staged deletion and a staged addition
+	if _, err := repo.worktree.Move("staged.go", "pkg/staged.go"); err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, &changedOnly, true)
+	setFlag(t, &sinceRef, "")
+	setFlag(t, &diffRef, "")
+
+	set, err := loadChangeSet(repo.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"edited.go":     "M",
+		"removed.go":    "D",
+		"pkg/new.go":    "A",
+		"moved.go":      "R",
+		"pkg/staged.go": "R",
+	}
+	if got := changeStatuses(t, set); !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	wantRenames := map[string]string{"moved.go": "old.go", "pkg/staged.go": "staged.go"}
+	if !reflect.DeepEqual(set.renamedFrom, wantRenames) {
+		t.Errorf("renamedFrom = %v, want %v", set.renamedFrom, wantRenames)
+	}
+	if !set.containsDir(repo.path("pkg")) {
+		t.Error("containsDir(pkg) = false, want true")
+	}
+	if got, want := set.deletedUnder(repo.dir), []string{repo.path("removed.go")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deletedUnder = %v, want %v", got, want)
+	}
+	if got := set.deletedUnder(repo.path("pkg")); len(got) != 0 {
+		t.Errorf("deletedUnder(pkg) = %v, want none", got)
+	}
+}
+
+func TestLoadChangeSetSince(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.write(t, "kept.go", "package kept\n")
+	repo.write(t, "edited.go", "package edited\n")
+	repo.write(t, "gone.go", "package gone\n")
+	repo.write(t, "first.go", "package first\n")
+	repo.write(t, "chained.go", "package chained\n")
+	base := repo.commit(t, "Initial commit")
+
+	repo.write(t, "edited.go", "package edited\n\nvar x = 1\n")
+	repo.remove(t, "gone.go")
+	repo.remove(t, "first.go")
+	repo.write(t, "second.go", "package first\n")
+	repo.remove(t, "chained.go")
+	repo.write(t, "chained2.go", "package chained\n")
+	repo.write(t, "added.go", "package added\n")
+	repo.commit(t, "Change things")
+
+	// Uncommitted changes count too, and keep the status relative to the ref
+	repo.write(t, "added.go", "package added\n\nvar y = 2\n")
+	repo.remove(t, "chained2.go")
+	repo.write(t, "chained3.go", "package chained\n")
+	setFlag(t, &changedOnly, false)
+	setFlag(t, &sinceRef, base)
+	setFlag(t, &diffRef, "")
+
+	set, err := loadChangeSet(repo.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"edited.go":   "M",
+		"gone.go":     "D",
+		"second.go":   "R",
+		"chained3.go": "R",
+		"added.go":    "A",
+	}
+	if got := changeStatuses(t, set); !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	for newPath, oldPath := range map[string]string{"second.go": "first.go", "chained3.go": "chained.go"} {
+		if got := set.renamedFrom[newPath]; got != oldPath {
+			t.Errorf("renamedFrom[%s] = %q, want %q", newPath, got, oldPath)
+		}
+	}
+	if set.diffTree != nil {
+		t.Error("diffTree is set without --diff")
+	}
+
+	setFlag(t, &sinceRef, "no-such-ref")
+	if _, err := loadChangeSet(repo.dir); err == nil {
+		t.Error("loadChangeSet accepted an unknown --since ref")
+	}
+}
+
+func TestLoadChangeSetNotARepository(t *testing.T) {
+	setFlag(t, &changedOnly, true)
+	if _, err := loadChangeSet(t.TempDir()); err == nil {
+		t.Error("loadChangeSet accepted a directory outside any repository")
+	}
+}
+
+func TestWorktreeChangeCode(t *testing.T) {
+	tests := []struct {
+		status git.FileStatus
+		want   string
+	}{
+		{git.FileStatus{Staging: git.Unmodified, Worktree: git.Unmodified}, ""},
+		{git.FileStatus{Staging: git.Untracked, Worktree: git.Untracked}, "A"},
+		{git.FileStatus{Staging: git.Added, Worktree: git.Unmodified}, "A"},
+		{git.FileStatus{Staging: git.Added, Worktree: git.Modified}, "A"},
+		{git.FileStatus{Staging: git.Unmodified, Worktree: git.Modified}, "M"},
+		{git.FileStatus{Staging: git.Modified, Worktree: git.Deleted}, "M"},
+		{git.FileStatus{Staging: git.UpdatedButUnmerged, Worktree: git.UpdatedButUnmerged}, "M"},
+		{git.FileStatus{Staging: git.Unmodified, Worktree: git.Deleted}, "D"},
+		{git.FileStatus{Staging: git.Deleted, Worktree: git.Unmodified}, "D"},
+	}
+	for _, tt := range tests {
+		status := tt.status
+		if got := worktreeChangeCode(&status); got != tt.want {
+			t.Errorf("worktreeChangeCode(%c%c) = %q, want %q", tt.status.Staging, tt.status.Worktree, got, tt.want)
+		}
+	}
+}
+
+func TestProcessLocalPathChanged(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.write(t, "main.go", "package main\n")
+	repo.write(t, "util.go", "package main\n\nfunc util() {}\n")
+	repo.write(t, "old.txt", "obsolete\n")
+	repo.write(t, ".hidden/gone.go", "package hidden\n")
+	repo.commit(t, "Initial commit")
+
+	repo.write(t, "main.go", "package main\n\nfunc main() {}\n")
+	repo.remove(t, "old.txt")
+	repo.remove(t, ".hidden/gone.go")
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	setFlag(t, &changedOnly, true)
+	setFlag(t, &sinceRef, "")
+	setFlag(t, &diffRef, "")
+	setFlag(t, &showHidden, false)
+
+	files, err := processLocalPath(repo.dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, file := range files {
+		rel, _ := filepath.Rel(repo.dir, file.Path)
+		got[filepath.ToSlash(rel)] = file.ChangeStatus + " " + file.SkipReason
+	}
+	// Unchanged files are left out; deleted ones are listed without content,
+	// unless a filter (here hidden paths) hides them
+	want := map[string]string{"main.go": "M ", "old.txt": "D " + deletedSkipReason}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+
+	// Single files are filtered by their status too
+	files, err = processLocalPath(repo.path("util.go"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("unchanged single file was kept: %v", files)
+	}
+	files, err = processLocalPath(repo.path("main.go"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].ChangeStatus != "M" {
+		t.Errorf("changed single file = %v, want it with status M", files)
+	}
+}
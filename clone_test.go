@@ -0,0 +1,291 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testRemote is a bare repository served over file://, with a working clone to
+// commit to it from.
+type testRemote struct {
+	url  string
+	work *git.Repository
+	dir  string // Directory of the working clone
+}
+
+// newTestRemote creates an empty bare repository and a working clone of it.
+func newTestRemote(t *testing.T) *testRemote {
+	t.Helper()
+	bare := filepath.Join(t.TempDir(), "remote.git")
+	if _, err := git.PlainInit(bare, true); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	work, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "file://" + filepath.ToSlash(bare)
+	if _, err := work.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{url}}); err != nil {
+		t.Fatal(err)
+	}
+	return &testRemote{url: url, work: work, dir: dir}
+}
+
+// commit writes a file in the working clone, commits it and pushes the commit
+// (and any tags) to the bare repository. It returns the commit SHA.
+func (r *testRemote) commit(t *testing.T, name, content string) string {
+	t.Helper()
+	writeTestFile(t, filepath.Join(r.dir, filepath.FromSlash(name)), content)
+	worktree, err := r.work.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := worktree.Commit("Update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.push(t)
+	return hash.String()
+}
+
+// tag tags HEAD of the working clone and pushes the tag.
+func (r *testRemote) tag(t *testing.T, name string) {
+	t.Helper()
+	head, err := r.work.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.work.CreateTag(name, head.Hash(), nil); err != nil {
+		t.Fatal(err)
+	}
+	r.push(t)
+}
+
+// checkout switches the working clone to branch, creating it at HEAD if asked.
+func (r *testRemote) checkout(t *testing.T, branch string, create bool) {
+	t.Helper()
+	worktree, err := r.work.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(branch), Create: create}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (r *testRemote) push(t *testing.T) {
+	t.Helper()
+	err := r.work.Push(&git.PushOptions{RemoteName: "origin", RefSpecs: []config.RefSpec{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"}})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		t.Fatal(err)
+	}
+}
+
+// useClone sets the flags cloneGitRepo reads for a plain clone straight from the
+// remote, and removes the clones it makes when the test ends.
+func useClone(t *testing.T) {
+	t.Helper()
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	setFlag(t, &noCache, true)
+	setFlag(t, &submoduleMode, "skip")
+	setFlag(t, &sinceRef, "")
+	setFlag(t, &diffRef, "")
+}
+
+// cloneForTest clones with cloneGitRepo and removes the clone when the test ends.
+func cloneForTest(t *testing.T, url, ref, subdir string) (string, string, error) {
+	t.Helper()
+	dir, sha, err := cloneGitRepo(url, ref, subdir)
+	if err == nil {
+		t.Cleanup(func() { os.RemoveAll(dir) })
+	}
+	return dir, sha, err
+}
+
+// readCloned returns the content of a file in a clone, or "" if it is missing.
+func readCloned(dir, name string) string {
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+func TestCloneGitRepoRefs(t *testing.T) {
+	useClone(t)
+	remote := newTestRemote(t)
+	first := remote.commit(t, "README.md", "# First\n")
+	remote.tag(t, "v1")
+	second := remote.commit(t, "README.md", "# Second\n")
+	remote.checkout(t, "feature", true)
+	feature := remote.commit(t, "README.md", "# Feature\n")
+	remote.checkout(t, "master", false)
+
+	tests := []struct {
+		name, ref, sha, content string
+	}{
+		{"default branch", "", second, "# Second\n"},
+		{"branch", "feature", feature, "# Feature\n"},
+		{"full branch ref", "refs/heads/feature", feature, "# Feature\n"},
+		{"tag", "v1", first, "# First\n"},
+		{"commit SHA", first, first, "# First\n"},
+		{"abbreviated SHA", first[:10], first, "# First\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, sha, err := cloneForTest(t, remote.url, tt.ref, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sha != tt.sha {
+				t.Errorf("SHA = %s, want %s", sha, tt.sha)
+			}
+			if got := readCloned(dir, "README.md"); got != tt.content {
+				t.Errorf("README.md = %q, want %q", got, tt.content)
+			}
+		})
+	}
+
+	// History is kept when --since needs it, so the base ref can be resolved in the clone
+	setFlag(t, &sinceRef, "v1")
+	dir, _, err := cloneForTest(t, remote.url, "feature", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloned, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cloned.CommitObject(plumbing.NewHash(first)); err != nil {
+		t.Errorf("clone for --since lacks older history: %v", err)
+	}
+}
+
+func TestCloneGitRepoErrors(t *testing.T) {
+	useClone(t)
+	remote := newTestRemote(t)
+	remote.commit(t, "README.md", "# Readme\n")
+
+	tests := []struct {
+		name, url, ref, subdir, want string
+	}{
+		{"unknown ref", remote.url, "no-such-branch", "", "ref 'no-such-branch' is not a branch, tag or commit SHA"},
+		{"unknown SHA", remote.url, strings.Repeat("ab", 20), "", "not found"},
+		{"missing subdirectory", remote.url, "", "docs", "subdirectory 'docs' not found in '" + remote.url + "'"},
+		{"missing repository", "file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "none.git")), "", "", "failed to clone repository"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := filepath.Glob(filepath.Join(os.TempDir(), "iris-git-*"))
+			_, _, err := cloneForTest(t, tt.url, tt.ref, tt.subdir)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("cloneGitRepo() error = %v, want it to contain %q", err, tt.want)
+			}
+			// Failed clones don't leave their temporary directory behind
+			if after, _ := filepath.Glob(filepath.Join(os.TempDir(), "iris-git-*")); len(after) > len(before) {
+				t.Errorf("temporary directories left behind: %v", after)
+			}
+		})
+	}
+}
+
+func TestResolveRemoteRef(t *testing.T) {
+	remote := newTestRemote(t)
+	sha := remote.commit(t, "README.md", "# Readme\n")
+	remote.tag(t, "v1.0.0")
+	remote.checkout(t, "release/2.x", true)
+	remote.commit(t, "README.md", "# Release\n")
+
+	tests := []struct {
+		ref     string
+		want    plumbing.ReferenceName
+		wantErr bool
+	}{
+		{"master", "refs/heads/master", false},
+		{"release/2.x", "refs/heads/release/2.x", false},
+		{"refs/heads/master", "refs/heads/master", false},
+		{"v1.0.0", "refs/tags/v1.0.0", false},
+		{sha, "", false},
+		{sha[:7], "", false},
+		{"missing", "", true},
+		{"HEAD", "", true}, // Not a branch or tag
+	}
+	for _, tt := range tests {
+		got, err := resolveRemoteRef(remote.url, remote.url, tt.ref)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("resolveRemoteRef(%q) = %q, %v; want %q (error %v)", tt.ref, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSplitTreePath(t *testing.T) {
+	remote := newTestRemote(t)
+	sha := remote.commit(t, "docs/README.md", "# Docs\n")
+	remote.tag(t, "v1")
+	remote.checkout(t, "feature/x", true)
+	remote.commit(t, "docs/feature.md", "# Feature\n")
+
+	tests := []struct {
+		treePath    string
+		ref, subdir string
+		wantErr     bool
+	}{
+		{"master", "master", "", false},
+		{"unknown", "unknown", "", false}, // A single segment is left for cloneGitRepo to check
+		{"master/docs", "master", "docs", false},
+		{"feature/x", "feature/x", "", false},
+		{"feature/x/docs", "feature/x", "docs", false},
+		{"v1/docs/api", "v1", "docs/api", false},
+		{sha[:12] + "/docs", sha[:12], "docs", false},
+		{"feature/y/docs", "", "", true},
+		{"missing/docs", "", "", true},
+	}
+	for _, tt := range tests {
+		ref, subdir, err := splitTreePath(remote.url, tt.treePath)
+		if ref != tt.ref || subdir != tt.subdir || (err != nil) != tt.wantErr {
+			t.Errorf("splitTreePath(%q) = %q, %q, %v; want %q, %q (error %v)", tt.treePath, ref, subdir, err, tt.ref, tt.subdir, tt.wantErr)
+		}
+	}
+}
+
+func TestCloneGitRepoSubdir(t *testing.T) {
+	useClone(t)
+	remote := newTestRemote(t)
+	remote.commit(t, "docs/guide/intro.md", "# Intro\n")
+	remote.commit(t, "src/main.go", "package main\n")
+	sha := remote.commit(t, "README.md", "# Readme\n")
+
+	for _, ref := range []string{"", "master", sha} {
+		dir, got, err := cloneForTest(t, remote.url, ref, "docs")
+		if err != nil {
+			t.Fatalf("ref %q: %v", ref, err)
+		}
+		if got != sha {
+			t.Errorf("ref %q: SHA = %s, want %s", ref, got, sha)
+		}
+		// Only the selected directory is checked out
+		if content := readCloned(dir, "docs/guide/intro.md"); content != "# Intro\n" {
+			t.Errorf("ref %q: docs/guide/intro.md = %q", ref, content)
+		}
+		for _, name := range []string{"src/main.go", "README.md"} {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+				t.Errorf("ref %q: %s checked out outside the subdirectory", ref, name)
+			}
+		}
+	}
+}
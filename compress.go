@@ -0,0 +1,158 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// blankRunPattern matches two or more consecutive blank lines.
+var blankRunPattern = regexp.MustCompile(`\n(?:[ \t]*\n){2,}`)
+
+// compressContent strips comments and docstrings (classified by the chroma lexer for
+// the file) and collapses runs of blank lines. Files without a matching lexer only
+// get the blank-line collapse. It reports whether the content changed.
+func compressContent(path string, content []byte, langData *LoadedLanguageData) ([]byte, bool) {
+	text := string(content)
+	if lexer := compressionLexer(path, langData); lexer != nil {
+		if stripped, err := stripComments(lexer, text); err == nil {
+			text = stripped
+		}
+	}
+	text = blankRunPattern.ReplaceAllString(text, "\n\n")
+
+	if text == string(content) {
+		return content, false
+	}
+	return []byte(text), true
+}
+
+// compressionLexer finds a lexer by filename, falling back to the languages.yml name.
+// Content analysis is deliberately not used: a wrong guess could strip real code.
+func compressionLexer(path string, langData *LoadedLanguageData) chroma.Lexer {
+	if isWebURL(path) {
+		return nil // Web pages are already converted Markdown
+	}
+	lexer := lexers.Match(filepath.Base(path))
+	if lexer == nil {
+		if lang, ok := langData.GetLanguageForFile(path); ok {
+			lexer = lexers.Get(lang)
+		}
+	}
+	if lexer == nil || lexer == lexers.Fallback {
+		return nil
+	}
+	return chroma.Coalesce(lexer)
+}
+
+// isStrippableToken reports whether a token is a comment or docstring that can be removed
+// without changing what the code does. Preprocessor lines, shebangs and Go directives are kept.
+func isStrippableToken(token chroma.Token) bool {
+	switch {
+	case token.Type == chroma.LiteralStringDoc:
+		return true
+	case !token.Type.InCategory(chroma.Comment):
+		return false
+	case token.Type == chroma.CommentPreproc, token.Type == chroma.CommentPreprocFile, token.Type == chroma.CommentHashbang:
+		return false
+	}
+	value := strings.TrimSpace(token.Value)
+	for _, directive := range []string{"//go:", "// +build", "//line ", "//export ", "#!"} {
+		if strings.HasPrefix(value, directive) {
+			return false
+		}
+	}
+	return true
+}
+
+// markPythonDocstrings marks triple-quoted strings that stand alone on their line.
+// Chroma lexes Python docstrings as ordinary strings, so they are recognized by position.
+func markPythonDocstrings(tokens []chroma.Token, strip []bool) {
+	atLineStart := true
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if atLineStart && token.Type.InCategory(chroma.LiteralString) {
+			j := i
+			var literal strings.Builder
+			for j < len(tokens) && tokens[j].Type.InCategory(chroma.LiteralString) {
+				if tokens[j].Type != chroma.LiteralStringAffix {
+					literal.WriteString(tokens[j].Value)
+				}
+				j++
+			}
+			value := literal.String()
+			tripleQuoted := strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''")
+			endsLine := j == len(tokens) || strings.HasPrefix(strings.TrimLeft(tokens[j].Value, " \t"), "\n")
+			if tripleQuoted && endsLine {
+				for k := i; k < j; k++ {
+					strip[k] = true
+				}
+			}
+			i = j - 1
+			atLineStart = false
+			continue
+		}
+		if token.Type == chroma.Text && strings.TrimSpace(token.Value) == "" {
+			if strings.Contains(token.Value, "\n") {
+				atLineStart = true
+			}
+			continue // Indentation keeps the current line state
+		}
+		atLineStart = strings.HasSuffix(token.Value, "\n")
+	}
+}
+
+// stripComments re-assembles the source without strippable tokens. Lines left empty by
+// a removed comment are dropped entirely and trailing whitespace is trimmed.
+func stripComments(lexer chroma.Lexer, source string) (string, error) {
+	iterator, err := lexer.Tokenise(nil, source)
+	if err != nil {
+		return "", err
+	}
+	tokens := iterator.Tokens()
+	strip := make([]bool, len(tokens))
+	for i, token := range tokens {
+		strip[i] = isStrippableToken(token)
+	}
+	if strings.Contains(lexer.Config().Name, "Python") {
+		markPythonDocstrings(tokens, strip)
+	}
+
+	var out, line strings.Builder
+	droppedOnLine := false
+	flush := func(newline bool) {
+		text := strings.TrimRight(line.String(), " \t")
+		if !(droppedOnLine && text == "") {
+			out.WriteString(text)
+			if newline {
+				out.WriteString("\n")
+			}
+		}
+		line.Reset()
+		droppedOnLine = false
+	}
+
+	for i, token := range tokens {
+		if strip[i] {
+			droppedOnLine = true
+			if strings.HasSuffix(token.Value, "\n") { // Line comments often include their newline
+				flush(true)
+			}
+			continue
+		}
+		parts := strings.Split(token.Value, "\n")
+		for i, part := range parts {
+			line.WriteString(part)
+			if i < len(parts)-1 {
+				flush(true)
+			}
+		}
+	}
+	if line.Len() > 0 {
+		flush(false)
+	}
+	return out.String(), nil
+}
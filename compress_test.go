@@ -0,0 +1,94 @@
+package main
+
+import "testing"
+
+func TestCompressContent(t *testing.T) {
+	tests := []struct {
+		name, path, content, want string
+	}{
+		{
+			name:    "go comments",
+			path:    "main.go",
+			content: "// Package main does it.\npackage main\n\n/* block\ncomment */\nfunc main() { // trailing\n\tx := 1 // set x\n}\n",
+			want:    "package main\n\nfunc main() {\n\tx := 1\n}\n",
+		},
+		{
+			name:    "comment markers inside strings",
+			path:    "main.go",
+			content: "package main\n\nvar url = \"http://example.com\" // home\nvar c = \"/* not a comment */\"\nvar r = `// raw`\n",
+			want:    "package main\n\nvar url = \"http://example.com\"\nvar c = \"/* not a comment */\"\nvar r = `// raw`\n",
+		},
+		{
+			name:    "go directives",
+			path:    "gen.go",
+			content: "//go:build linux\n\n// Comment.\npackage gen\n\n//go:generate stringer -type=T\ntype T int\n",
+			want:    "//go:build linux\n\npackage gen\n\n//go:generate stringer -type=T\ntype T int\n",
+		},
+		{
+			name:    "python docstrings",
+			path:    "mod.py",
+			content: "#!/usr/bin/env python\n\"\"\"Module docstring.\"\"\"\n\n\ndef f():\n    \"\"\"Function docstring.\n\n    More.\n    \"\"\"\n    s = \"\"\"kept: assigned\"\"\"\n    return s  # comment\n",
+			want:    "#!/usr/bin/env python\n\ndef f():\n    s = \"\"\"kept: assigned\"\"\"\n    return s\n",
+		},
+		{
+			name:    "python hash inside strings",
+			path:    "mod.py",
+			content: "x = \"# not a comment\"\ny = '#'  # a comment\n",
+			want:    "x = \"# not a comment\"\ny = '#'\n",
+		},
+		{
+			name:    "c preprocessor",
+			path:    "main.c",
+			content: "#include <stdio.h>\n// comment\nint main(void) { return 0; }\n",
+			want:    "#include <stdio.h>\nint main(void) { return 0; }\n",
+		},
+		{
+			name:    "blank-line runs",
+			path:    "notes.txt",
+			content: "first\n\n\n\nsecond\n \t\n\nthird\n\nfourth\n",
+			want:    "first\n\nsecond\n\nthird\n\nfourth\n",
+		},
+		{
+			name:    "blank-line runs left by comments",
+			path:    "main.go",
+			content: "package main\n\n// one\n\n// two\n\n\nfunc main() {}\n",
+			want:    "package main\n\nfunc main() {}\n",
+		},
+		{
+			name:    "nothing to compress",
+			path:    "main.go",
+			content: "package main\n\nfunc main() {}\n",
+			want:    "package main\n\nfunc main() {}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := compressContent(tt.path, []byte(tt.content), nil)
+			if string(got) != tt.want {
+				t.Errorf("compressContent() =\n%q\nwant\n%q", got, tt.want)
+			}
+			if changed != (tt.content != tt.want) {
+				t.Errorf("compressContent() changed = %v", changed)
+			}
+		})
+	}
+}
+
+func TestCompressionStatCountsRawContent(t *testing.T) {
+	setFlag(t, &compressMode, true)
+	setTruncation(t, 2, 0)
+	content := "package main\n\n// comment\nfunc a() {}\nfunc b() {}\nfunc c() {}\n"
+	compressed := "package main\n\nfunc a() {}\nfunc b() {}\nfunc c() {}\n"
+
+	file, emitted := processContent(FileInfo{Path: "main.go", Size: int64(len(content))}, []byte(content), byteTokenizer{})
+	if file.Compression == nil {
+		t.Fatal("no compression stat recorded")
+	}
+	// Savings compare the raw content with the compressed content, before truncation cut it further
+	if file.Compression.Before != len(content) || file.Compression.After != len(compressed) {
+		t.Errorf("Compression = %+v, want Before %d and After %d", *file.Compression, len(content), len(compressed))
+	}
+	if file.TokenCount != len(emitted) || string(emitted) == compressed {
+		t.Errorf("TokenCount = %d for %q, want the truncated content counted", file.TokenCount, emitted)
+	}
+}
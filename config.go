@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// projectConfigFileName is the per-project config discovered by walking up from the input path.
+const projectConfigFileName = ".iris.toml"
+
+// configKeyAliases maps flag-derived config keys to the legacy config.toml names that also set them.
+var configKeyAliases = map[string][]string{
+	"output":     {"default_output_format"},
+	"tokenizer":  {"default_tokenizer"},
+	"model":      {"default_tokenizer_model"},
+	"link_depth": {"default_link_depth"},
+}
+
+// projectConfigKeys are the settings a project's .iris.toml may change: what gets
+// selected and filtered, how content is transformed, and the output format. The
+// project config is read from the tree being scanned, which may not be trusted, so
+// keys that name files to read or write (file, pdf, template, files_from,
+// tokenizer_file), pick the destination (clipboard, print), reach the network or
+// widen what is read (hidden, no_ignore, include_binary) are ignored there, and
+// secret protections can only be tightened (see projectEnableOnlyKeys).
+var projectConfigKeys = map[string]bool{
+	// Filtering
+	"include": true, "exclude": true, "default_excludes": true, "max_size": true,
+	"max_depth": true, "skip_generated": true, "skip_vendored": true, "submodules": true,
+	"changed": true, "since": true, "diff": true, "diff_full": true,
+	// Secrets
+	"redact": true, "fail_on_secrets": true, "redact_rules": true,
+	// Format and content transformations
+	"output": true, "default_output_format": true, "format": true,
+	"compress": true, "outline": true, "truncate_lines": true, "truncate_tokens": true,
+	"max_tokens": true, "budget_strategy": true, "priority": true,
+	"git_log": true, "git_log_scope": true, "blame_summary": true, "git_context_formats": true,
+}
+
+// projectEnableOnlyKeys are the secret protections a project config may turn on
+// but not off. Its redact_rules are added to the configured rules, not replacing them.
+var projectEnableOnlyKeys = map[string]bool{"redact": true, "fail_on_secrets": true}
+
+// configLayer is a config file that contributed settings, kept for --debug-config.
+type configLayer struct {
+	name string
+	path string
+	keys map[string]bool
+}
+
+// configLayers lists the loaded config files from lowest to highest precedence.
+var configLayers []configLayer
+
+// recordConfigLayer remembers which keys a config file sets. keep, if not nil,
+// selects the keys that were actually applied.
+func recordConfigLayer(name, path string, keep func(key string) bool) {
+	layer := configLayer{name: name, path: path, keys: make(map[string]bool)}
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err == nil {
+		for _, key := range v.AllKeys() {
+			if keep != nil && !keep(key) {
+				continue
+			}
+			layer.keys[key] = true
+		}
+	}
+	configLayers = append(configLayers, layer)
+}
+
+// projectConfigStart returns the directory from which to search for .iris.toml:
+// the first local input (or its parent directory for files), else the working directory.
+func projectConfigStart(args []string) string {
+	for _, arg := range args {
+		if arg == "" || isWebURL(arg) || isGitURL(arg) {
+			continue
+		}
+		info, err := os.Stat(arg)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			return arg
+		}
+		return filepath.Dir(arg)
+	}
+	return "."
+}
+
+// findProjectConfig walks up from start and returns the nearest .iris.toml, or "".
+func findProjectConfig(start string) string {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, projectConfigFileName)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// mergeProjectConfig merges a project config over the already loaded global config
+// and returns the top-level keys it applied. Only projectConfigKeys are merged;
+// other keys, and attempts to turn secret protections off, are ignored with a warning.
+func mergeProjectConfig(path string) (map[string]bool, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	settings := v.AllSettings()
+	for key, value := range settings {
+		switch {
+		case !projectConfigKeys[key]:
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s in project config %s, only filtering and format settings apply there\n", key, path)
+			delete(settings, key)
+		case projectEnableOnlyKeys[key] && value != true:
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s in project config %s, it can only be turned on there\n", key, path)
+			delete(settings, key)
+		}
+	}
+	if rules, ok := settings["redact_rules"].([]interface{}); ok {
+		configured, _ := viper.Get("redact_rules").([]interface{})
+		settings["redact_rules"] = append(append([]interface{}(nil), configured...), rules...)
+	}
+
+	applied := make(map[string]bool, len(settings))
+	for key := range settings {
+		applied[key] = true
+	}
+	return applied, viper.MergeConfigMap(settings)
+}
+
+// configKeysForFlag returns the config keys that can set a flag, primary key first.
+func configKeysForFlag(name string) []string {
+	key := strings.ReplaceAll(name, "-", "_")
+	return append([]string{key}, configKeyAliases[key]...)
+}
+
+// envProvides reports whether an IRIS_* environment variable sets key.
+func envProvides(key string) bool {
+	_, ok := os.LookupEnv("IRIS_" + strings.ToUpper(key))
+	return ok
+}
+
+// applyConfigToFlags copies values from config files and the environment into flags
+// that were not set on the command line.
+func applyConfigToFlags() {
+	rootCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed || f.Name == "exclude" || f.Name == "help" || f.Name == "version" {
+			return // exclude is resolved through default_excludes by initConfig
+		}
+		for _, key := range configKeysForFlag(f.Name) {
+			if !envProvides(key) && !viper.InConfig(key) {
+				continue
+			}
+			if err := f.Value.Set(configValueString(viper.Get(key))); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: invalid value for config key %s: %v\n", key, err)
+			}
+			return
+		}
+	})
+}
+
+// configValueString converts a config value to flag syntax; arrays become comma-separated lists.
+func configValueString(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, ",")
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// configSource describes where the effective value of a flag came from.
+func configSource(f *pflag.Flag) string {
+	if f.Changed {
+		return "flag"
+	}
+	keys := configKeysForFlag(f.Name)
+	if f.Name == "exclude" {
+		keys = append(keys, "default_excludes")
+	}
+	for _, key := range keys {
+		if envProvides(key) {
+			return "env IRIS_" + strings.ToUpper(key)
+		}
+	}
+	for i := len(configLayers) - 1; i >= 0; i-- {
+		for _, key := range keys {
+			if configLayers[i].keys[key] {
+				return fmt.Sprintf("%s %s", configLayers[i].name, configLayers[i].path)
+			}
+		}
+	}
+	return "default"
+}
+
+// printConfigDebug prints the config precedence chain and each setting's effective value and source.
+func printConfigDebug() {
+	fmt.Fprintln(os.Stderr, "Config precedence (lowest to highest):")
+	fmt.Fprintln(os.Stderr, "  1. built-in defaults")
+	for i, layer := range configLayers {
+		fmt.Fprintf(os.Stderr, "  %d. %s: %s\n", i+2, layer.name, layer.path)
+	}
+	fmt.Fprintf(os.Stderr, "  %d. environment variables (IRIS_*)\n", len(configLayers)+2)
+	fmt.Fprintf(os.Stderr, "  %d. command-line flags\n", len(configLayers)+3)
+
+	fmt.Fprintln(os.Stderr, "Effective settings:")
+	rootCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" || f.Name == "version" || f.Name == "debug-config" {
+			return
+		}
+		value := f.Value.String()
+		if f.Name == "exclude" {
+			value = excludePatterns
+		}
+		fmt.Fprintf(os.Stderr, "  %s = %s (%s)\n", f.Name, value, configSource(f))
+	})
+}
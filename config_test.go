@@ -0,0 +1,123 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// loadTestConfigs loads global as the global config (if not empty) and merges
+// project over it, returning the project keys that were applied.
+func loadTestConfigs(t *testing.T, global, project string) map[string]bool {
+	t.Helper()
+	// Configs are merged into the global viper; start from and leave a clean one
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	dir := t.TempDir()
+	if global != "" {
+		path := filepath.Join(dir, "config.toml")
+		writeTestFile(t, path, global)
+		viper.SetConfigFile(path)
+		if err := viper.ReadInConfig(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, ".iris.toml")
+	writeTestFile(t, path, project)
+	applied, err := mergeProjectConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return applied
+}
+
+func TestMergeProjectConfig(t *testing.T) {
+	applied := loadTestConfigs(t, "", `include = "*.go"
+max_size = 2048
+output = "xml"
+file = "/etc/cron.d/iris"
+template = "/tmp/evil.tmpl"
+files_from = "/etc/passwd"
+clipboard = true
+hidden = true
+no_ignore = true
+include_binary = "base64"
+`)
+
+	for _, key := range []string{"include", "max_size", "output"} {
+		if !viper.InConfig(key) || !applied[key] {
+			t.Errorf("project config key %s was not applied", key)
+		}
+	}
+	for _, key := range []string{"file", "template", "files_from", "clipboard", "hidden", "no_ignore", "include_binary"} {
+		if viper.InConfig(key) || applied[key] {
+			t.Errorf("project config key %s was applied, want it ignored", key)
+		}
+	}
+	if got := viper.GetString("include"); got != "*.go" {
+		t.Errorf("include = %q, want *.go", got)
+	}
+}
+
+func TestMergeProjectConfigSecretProtections(t *testing.T) {
+	tests := []struct {
+		name, global, project string
+		redact, failOnSecrets bool
+	}{
+		{
+			name:          "project cannot turn protections off",
+			global:        "redact = true\nfail_on_secrets = true\n",
+			project:       "redact = false\nfail_on_secrets = false\n",
+			redact:        true,
+			failOnSecrets: true,
+		},
+		{
+			name:          "project can turn protections on",
+			project:       "redact = true\nfail_on_secrets = true\n",
+			redact:        true,
+			failOnSecrets: true,
+		},
+		{
+			name:    "project leaves protections alone",
+			global:  "redact = true\n",
+			project: "include = \"*.go\"\n",
+			redact:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := loadTestConfigs(t, tt.global, tt.project)
+			if got := viper.GetBool("redact"); got != tt.redact {
+				t.Errorf("redact = %v, want %v", got, tt.redact)
+			}
+			if got := viper.GetBool("fail_on_secrets"); got != tt.failOnSecrets {
+				t.Errorf("fail_on_secrets = %v, want %v", got, tt.failOnSecrets)
+			}
+			// --debug-config must not attribute a refused value to the project
+			for _, key := range []string{"redact", "fail_on_secrets"} {
+				if applied[key] && !viper.GetBool(key) {
+					t.Errorf("%s reported as applied from the project config", key)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeProjectConfigAddsRedactRules(t *testing.T) {
+	loadTestConfigs(t, `[[redact_rules]]
+name = "global-token"
+pattern = "gtk_[0-9a-f]{8}"
+`, `[[redact_rules]]
+name = "project-token"
+pattern = "ptk_[0-9a-f]{8}"
+`)
+	var rules []configSecretRule
+	if err := viper.UnmarshalKey("redact_rules", &rules); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Name != "global-token" || rules[1].Name != "project-token" {
+		t.Errorf("redact_rules = %+v, want the global rule followed by the project rule", rules)
+	}
+}
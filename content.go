@@ -0,0 +1,99 @@
+package main
+
+import "fmt"
+
+// processContent runs the per-file content stages on freshly read content and
+// records what the output needs to know about the result: binary and generated
+// file detection, the language, secrets, and token counts of what will actually
+// be emitted. It returns the processed file and its emitted content, nil when the
+// content is withheld. tk may be nil when token counting is disabled.
+func processContent(file FileInfo, content []byte, tk Tokenizer) (FileInfo, []byte) {
+	file, original, emitted, transformed := transformContent(file, content, tk)
+	if file.SkipReason != "" {
+		return file, nil
+	}
+	file.Language = detectLanguage(file.Path, emitted, langData)
+	if tk != nil && len(emitted) > 0 {
+		file.TokenCount = tk.CountTokens(string(emitted))
+		if exceedsMaxSize(file) && file.Diff == nil && !file.Binary {
+			// Only an excerpt was read, so the count for the whole file is an estimate
+			file.OriginalTokenCount = estimateOriginalTokens(file, original, tk)
+		} else if transformed {
+			file.OriginalTokenCount = tk.CountTokens(string(original))
+		}
+		if maxTokens > 0 {
+			file.OutputTokens = outputContentTokens(file, emitted, tk)
+		}
+	}
+	return file, emitted
+}
+
+// transformContent turns a file's content into what it emits: binary and
+// generated files are detected and skipped (or binaries encoded), --diff replaces
+// the content with a unified diff, secrets are redacted, then --outline, --compress
+// and truncation apply. It returns the content before and after the
+// transformations (redaction and encoding are part of both) and whether a
+// transformation changed it.
+func transformContent(file FileInfo, content []byte, tk Tokenizer) (FileInfo, []byte, []byte, bool) {
+	if binary, contentType := isBinaryContent(content); binary {
+		file.Binary = true
+		if includeBinary == "" {
+			file.SkipReason = binarySkipReason // Listed in the tree, content withheld
+			recordSkip(file.Path, fmt.Sprintf("%s (%s)", binarySkipReason, contentType))
+			return file, nil, nil, false
+		}
+		// The encoded form is what gets emitted, so it is what the later stages see
+		content = encodeBinaryContent(content, file.Size, contentType)
+	}
+
+	if skipGenerated && !file.Binary {
+		if reason := generatedReason(file.Path, content, langData); reason != "" {
+			file.SkipReason = generatedSkipReason
+			recordSkip(file.Path, fmt.Sprintf("%s (%s)", generatedSkipReason, reason))
+			return file, nil, nil, false
+		}
+	}
+
+	// With --diff the emitted content is the diff against the ref, not the file
+	if file.Diff != nil {
+		content = unifiedDiff(file, content)
+	}
+
+	// Redaction rewrites the baseline itself, so it doesn't count as a transformation
+	if secretScanEnabled() {
+		var secrets map[string]int
+		content, secrets = scanSecrets(content, redactSecrets)
+		if len(secrets) > 0 {
+			file.Secrets = secrets
+		}
+	}
+
+	original := content
+	transformed := false
+
+	if outlineMode && file.Diff == nil {
+		var outlined bool
+		content, outlined = outlineContent(file.Path, content)
+		transformed = transformed || outlined
+	}
+
+	if compressMode && file.Diff == nil {
+		before := content
+		var compressed bool
+		content, compressed = compressContent(file.Path, content, langData)
+		file.Compressed = compressed
+		transformed = transformed || compressed
+		if compressed && tk != nil {
+			// Savings are measured on the content compression saw, before truncation
+			file.Compression = &CompressionStat{Path: file.Path, Before: tk.CountTokens(string(before)), After: tk.CountTokens(string(content))}
+		}
+	}
+
+	if truncationEnabled() {
+		var truncated bool
+		content, truncated = truncateContent(content, tk)
+		transformed = transformed || truncated
+	}
+
+	return file, original, content, transformed
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	gitdiff "github.com/go-git/go-git/v5/utils/diff"
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diffContextLines is the number of unchanged lines around each hunk, like git diff.
+const diffContextLines = 3
+
+// diffInput is the version of a changed file at the --diff ref. The new version
+// is the file's current content, read by the workers. The ref version is only
+// read when the worker diffs the file, so old blobs aren't held for the whole run.
+type diffInput struct {
+	OldPath string // Slash-separated path in the repository at the ref, "" if the file is new
+	NewPath string // Slash-separated path in the repository now, "" if the file was deleted
+	OldMode filemode.FileMode
+	oldBlob plumbing.Hash // Blob of the ref version, read through objects
+	objects *gitObjects
+}
+
+// gitObjects reads blobs from a repository's object storage for the workers.
+// go-git storage isn't safe for concurrent use, so reads are serialised.
+type gitObjects struct {
+	mu     sync.Mutex
+	storer storer.EncodedObjectStorer
+}
+
+// old reads the ref version of the file, nil if the file is new.
+func (d *diffInput) old() ([]byte, error) {
+	if d.OldPath == "" {
+		return nil, nil
+	}
+	d.objects.mu.Lock()
+	defer d.objects.mu.Unlock()
+	blob, err := object.GetBlob(d.objects.storer, d.oldBlob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", d.OldPath, diffRef, err)
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", d.OldPath, diffRef, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// diffEnabled reports whether --diff replaces file content with unified diffs.
+func diffEnabled() bool {
+	return diffRef != ""
+}
+
+// diffInputFor looks up the ref side of the diff for a changed file. Its content
+// is read later, by the worker diffing the file.
+func (c *changeSet) diffInputFor(path string) (*diffInput, error) {
+	rel, ok := c.repoPath(path)
+	if !ok {
+		return nil, fmt.Errorf("%s is outside the repository", path)
+	}
+	status, _ := c.status(path)
+
+	input := &diffInput{NewPath: rel}
+	if status == "D" {
+		input.NewPath = ""
+	}
+
+	oldPath := rel
+	if renamed, ok := c.renamedFrom[rel]; ok {
+		oldPath = renamed
+	}
+	if c.diffTree == nil {
+		return input, nil
+	}
+	file, err := c.diffTree.File(oldPath)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return input, nil // Added since the ref
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", oldPath, diffRef, err)
+	}
+	input.OldPath = oldPath
+	input.OldMode = file.Mode
+	input.oldBlob = file.Hash
+	input.objects = c.objects
+	return input, nil
+}
+
+// unifiedDiff renders the diff from the ref version of a file to content in git's
+// unified format. With --diff-full the current file follows the diff for context.
+func unifiedDiff(file FileInfo, content []byte) []byte {
+	input := file.Diff
+	old, err := input.old()
+	if err != nil {
+		return []byte(fmt.Sprintf("[diff failed: %v]\n", err))
+	}
+	newMode, err := filemode.NewFromOSFileMode(file.Mode)
+	if err != nil || file.Mode == 0 {
+		newMode = filemode.Regular
+	}
+
+	patch := &diffPatch{file: &diffFilePatch{binary: isBinaryDiffSide(old) || isBinaryDiffSide(content)}}
+	if input.OldPath != "" {
+		patch.file.from = &diffFile{path: input.OldPath, mode: input.OldMode, content: old}
+	}
+	if input.NewPath != "" {
+		patch.file.to = &diffFile{path: input.NewPath, mode: newMode, content: content}
+	}
+	if !patch.file.binary {
+		patch.file.chunks = diffChunks(string(old), string(content))
+	}
+
+	var b strings.Builder
+	if err := fdiff.NewUnifiedEncoder(&b, diffContextLines).Encode(patch); err != nil {
+		fmt.Fprintf(&b, "[diff failed: %v]\n", err)
+	}
+	if diffFull && input.NewPath != "" && !patch.file.binary {
+		fmt.Fprintf(&b, "\n--- full file: %s ---\n", input.NewPath)
+		b.Write(content)
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+	}
+	return []byte(b.String())
+}
+
+// isBinaryDiffSide reports whether one side of a diff is binary.
+func isBinaryDiffSide(content []byte) bool {
+	binary, _ := isBinaryContent(content)
+	return binary
+}
+
+// diffChunks converts a line diff into go-git's chunk representation, the same way
+// go-git does for commit patches.
+func diffChunks(from, to string) []fdiff.Chunk {
+	var chunks []fdiff.Chunk
+	for _, d := range gitdiff.Do(from, to) {
+		var op fdiff.Operation
+		switch d.Type {
+		case dmp.DiffEqual:
+			op = fdiff.Equal
+		case dmp.DiffDelete:
+			op = fdiff.Delete
+		case dmp.DiffInsert:
+			op = fdiff.Add
+		}
+		chunks = append(chunks, diffChunk{content: d.Text, op: op})
+	}
+	return chunks
+}
+
+// diffPatch, diffFilePatch, diffFile and diffChunk implement go-git's diff
+// interfaces for a ref blob against a working tree file.
+type diffPatch struct {
+	file *diffFilePatch
+}
+
+func (p *diffPatch) FilePatches() []fdiff.FilePatch { return []fdiff.FilePatch{p.file} }
+func (p *diffPatch) Message() string                { return "" }
+
+type diffFilePatch struct {
+	from, to *diffFile
+	binary   bool
+	chunks   []fdiff.Chunk
+}
+
+func (p *diffFilePatch) IsBinary() bool        { return p.binary }
+func (p *diffFilePatch) Chunks() []fdiff.Chunk { return p.chunks }
+func (p *diffFilePatch) Files() (from, to fdiff.File) {
+	// Return untyped nils so the encoder sees added/deleted files
+	if p.from != nil {
+		from = p.from
+	}
+	if p.to != nil {
+		to = p.to
+	}
+	return from, to
+}
+
+type diffFile struct {
+	path    string
+	mode    filemode.FileMode
+	content []byte
+}
+
+func (f *diffFile) Hash() plumbing.Hash {
+	return plumbing.ComputeHash(plumbing.BlobObject, f.content)
+}
+func (f *diffFile) Mode() filemode.FileMode { return f.mode }
+func (f *diffFile) Path() string            { return f.path }
+
+type diffChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c diffChunk) Content() string       { return c.content }
+func (c diffChunk) Type() fdiff.Operation { return c.op }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// diffTestRepo commits a few files and changes them in the working tree: one
+// modified, one added, one deleted and one renamed.
+func diffTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	repo := newTestRepo(t)
+	repo.write(t, "edited.txt", "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n")
+	repo.write(t, "removed.txt", "gone\nfor good\n")
+	repo.write(t, "old.txt", "moved content\n")
+	repo.commit(t, "Initial commit")
+
+	repo.write(t, "edited.txt", "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n")
+	repo.write(t, "added.txt", "brand\nnew\n")
+	repo.remove(t, "removed.txt")
+	repo.remove(t, "old.txt")
+	repo.write(t, "new.txt", "moved content\n")
+	return repo
+}
+
+// diffFor diffs a file of repo against --diff HEAD.
+func diffFor(t *testing.T, repo *testRepo, name string) string {
+	t.Helper()
+	set, err := loadChangeSet(repo.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := repo.path(name)
+	input, err := set.diffInputFor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(unifiedDiff(FileInfo{Path: path, Mode: 0o644, Diff: input}, content))
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	repo := diffTestRepo(t)
+	setFlag(t, &changedOnly, false)
+	setFlag(t, &sinceRef, "")
+	setFlag(t, &diffRef, "HEAD")
+	setFlag(t, &diffFull, false)
+
+	tests := []struct {
+		name, want string
+	}{
+		{"edited.txt", `diff --git a/edited.txt b/edited.txt
+index c9e9e05f445e6b772f19fea1449759b7458a446e..5d4832f9592547f0bbf90d973e7e87daa1212292 100644
+--- a/edited.txt
++++ b/edited.txt
+@@ -1,6 +1,6 @@
+ one
+ two
+-three
++THREE
+ four
+ five
+ six
+@@ -8,3 +8,4 @@ seven
+ eight
+ nine
+ ten
++eleven
+`},
+		{"added.txt", `diff --git a/added.txt b/added.txt
+new file mode 100644
+index 0000000000000000000000000000000000000000..5786b13ddf42af9492f8f94243b3397cded5d953
+--- /dev/null
++++ b/added.txt
+@@ -0,0 +1,2 @@
++brand
++new
+`},
+		{"removed.txt", `diff --git a/removed.txt b/removed.txt
+deleted file mode 100644
+index 4208d7e2a316ef3f6ba82d1f15869e9374642278..0000000000000000000000000000000000000000
+--- a/removed.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-gone
+-for good
+`},
+		{"new.txt", `diff --git a/old.txt b/new.txt
+rename from old.txt
+rename to new.txt
+`},
+	}
+	for _, tt := range tests {
+		if got := diffFor(t, repo, tt.name); got != tt.want {
+			t.Errorf("diff of %s =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnifiedDiffFull(t *testing.T) {
+	repo := diffTestRepo(t)
+	setFlag(t, &changedOnly, false)
+	setFlag(t, &sinceRef, "")
+	setFlag(t, &diffRef, "HEAD")
+	setFlag(t, &diffFull, true)
+
+	// The current file follows the diff
+	got := diffFor(t, repo, "added.txt")
+	want := `diff --git a/added.txt b/added.txt
+new file mode 100644
+index 0000000000000000000000000000000000000000..5786b13ddf42af9492f8f94243b3397cded5d953
+--- /dev/null
++++ b/added.txt
+@@ -0,0 +1,2 @@
++brand
++new
+
+--- full file: added.txt ---
+brand
+new
+`
+	if got != want {
+		t.Errorf("diff of added.txt =\n%s\nwant\n%s", got, want)
+	}
+
+	// A deleted file has no current content to show
+	if got := diffFor(t, repo, "removed.txt"); strings.Contains(got, "--- full file") {
+		t.Errorf("diff of a deleted file shows a full file:\n%s", got)
+	}
+}
+
+func TestUnifiedDiffBinary(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.write(t, "logo.bin", "\x00\x01\x02")
+	repo.commit(t, "Initial commit")
+	repo.write(t, "logo.bin", "\x00\x01\x03")
+	setFlag(t, &changedOnly, false)
+	setFlag(t, &sinceRef, "")
+	setFlag(t, &diffRef, "HEAD")
+	setFlag(t, &diffFull, true)
+
+	got := diffFor(t, repo, "logo.bin")
+	want := "diff --git a/logo.bin b/logo.bin\nindex 8352675d67aed6625ece79af41c27fdb4ee2e867..1592e5c60f1a460928916dc5681fee1a9bd10868 100644\nBinary files a/logo.bin and b/logo.bin differ\n"
+	if got != want {
+		t.Errorf("diff of a binary file =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// excludedSkipReason marks files and directories matching an --exclude pattern.
+const excludedSkipReason = "excluded by pattern"
+
+// entryFilter applies the filters shared by directory walks, single file arguments
+// and archive listings: hidden names, --skip-vendored, --max-depth, --exclude,
+// --include (or known languages) and --max-size. Paths are matched slash-separated
+// and relative to the input. Ignore files and change selection need a real
+// filesystem and stay with the walk.
+type entryFilter struct {
+	includes []string
+	excludes []string
+	langData *LoadedLanguageData
+}
+
+// newEntryFilter parses the --include and --exclude patterns once per input.
+func newEntryFilter(langData *LoadedLanguageData) entryFilter {
+	return entryFilter{
+		includes: parsePatterns(includePatterns),
+		excludes: parsePatterns(excludePatterns),
+		langData: langData,
+	}
+}
+
+// hidden reports whether a file or directory name is hidden and --hidden is off.
+func (f entryFilter) hidden(name string) bool {
+	return !showHidden && isHidden(name)
+}
+
+// skipDir reports whether the directory at relPath is pruned. The reason is ""
+// for silent skips (depth) and otherwise recorded in the --verbose report.
+func (f entryFilter) skipDir(name, relPath string) (string, bool) {
+	// Vendored dependency trees are pruned whole with --skip-vendored
+	if skipVendored && isVendoredDir(name) {
+		return vendoredSkipReason, true
+	}
+	if maxDepth > 0 && countPathSeparators(relPath) >= maxDepth {
+		return "", true // Reached max depth
+	}
+	// e.g. "**/node_modules/**" prunes the whole subtree
+	excluded, err := matchesAnyPattern(relPath, f.excludes, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: error in exclude pattern matching for %s: %v\n", relPath, err)
+	}
+	if excluded {
+		return excludedSkipReason, true
+	}
+	return "", false
+}
+
+// skipFile reports whether the file at relPath is filtered out by --exclude,
+// --include or, without includes, by not belonging to a known language. path is
+// used for language detection. The reason is "" for files that simply weren't selected.
+func (f entryFilter) skipFile(path, relPath string) (string, bool) {
+	excluded, err := matchesAnyPattern(relPath, f.excludes, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: error in exclude pattern matching for %s: %v\n", path, err)
+	}
+	if excluded {
+		return excludedSkipReason, true
+	}
+
+	if len(f.includes) > 0 {
+		// If includes are specified, use them
+		included, err := matchesAnyPattern(relPath, f.includes, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: error in include pattern matching for %s: %v\n", path, err)
+		}
+		return "", !included
+	}
+	if f.langData != nil {
+		// If no includes specified AND langData exists, check language
+		_, knownLang := f.langData.GetLanguageForFile(path)
+		return "", !knownLang
+	}
+	return "", false // No includes, no langData -> keep all non-excluded files
+}
+
+// skipSize reports whether a file is larger than --max-size. Such files are kept
+// when truncation is enabled, but only their head and tail are read (see readTruncated).
+func (f entryFilter) skipSize(size int64) (string, bool) {
+	if maxSizeBytes > 0 && size > maxSizeBytes && !truncationEnabled() {
+		return fmt.Sprintf("larger than --max-size (%d bytes)", size), true
+	}
+	return "", false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// generatedSkipReason marks generated files skipped by --skip-generated.
+const generatedSkipReason = "generated"
+
+// vendoredSkipReason marks directories pruned by --skip-vendored.
+const vendoredSkipReason = "vendored"
+
+// defaultVendoredDirs are directory names holding third-party code copied into a project.
+var defaultVendoredDirs = []string{"vendor", "third_party", "third-party", "bower_components"}
+
+// defaultGeneratedPatterns are file name patterns that are always machine-written.
+// Languages can add their own with a `generated` list in languages.yml.
+var defaultGeneratedPatterns = []string{
+	// Protocol buffers and gRPC
+	"*.pb.go", "*.pb.gw.go", "*_pb2.py", "*_pb2_grpc.py", "*.pb.h", "*.pb.cc",
+	// Lockfiles
+	"go.sum", "package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml",
+	"Cargo.lock", "Gemfile.lock", "composer.lock", "poetry.lock", "Pipfile.lock",
+	// Minified assets
+	"*.min.js", "*.min.css",
+}
+
+// generatedHeaderPattern matches Go's `// Code generated ... DO NOT EDIT.` convention
+// (https://go.dev/s/generatedcode) and the `@generated` marker used by other tools.
+var generatedHeaderPattern = regexp.MustCompile(`^(//|#|/\*|\*|--)\s*(Code generated .* DO NOT EDIT\.?|.*@generated\b)`)
+
+// generatedHeaderLines is how many leading lines are searched for a generated header.
+const generatedHeaderLines = 50
+
+// minifiedLineLength is the line length above which JS/CSS is treated as minified.
+const minifiedLineLength = 500
+
+// minifiableExtensions are the extensions checked for minified content.
+var minifiableExtensions = []string{".js", ".mjs", ".cjs", ".css"}
+
+// isVendoredDir reports whether a directory name is a vendored dependency tree.
+func isVendoredDir(name string) bool {
+	return containsString(defaultVendoredDirs, name)
+}
+
+// generatedReason reports why a file looks generated, or "" if it doesn't.
+// It checks the file name against the built-in and languages.yml patterns, the
+// leading comment block for a generated header, and JS/CSS for minification.
+func generatedReason(path string, content []byte, langData *LoadedLanguageData) string {
+	baseName := filepath.Base(path)
+	patterns := append(append([]string(nil), defaultGeneratedPatterns...), langData.GeneratedPatterns()...)
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, baseName); matched {
+			return "name matches " + pattern
+		}
+	}
+
+	for i, line := range bytes.SplitN(content, []byte("\n"), generatedHeaderLines+1) {
+		if i == generatedHeaderLines {
+			break // The last element is the unsplit rest of the file
+		}
+		if generatedHeaderPattern.Match(bytes.TrimSpace(line)) {
+			return "generated header comment"
+		}
+	}
+
+	if containsString(minifiableExtensions, strings.ToLower(filepath.Ext(baseName))) {
+		for _, line := range bytes.Split(content, []byte("\n")) {
+			if len(line) > minifiedLineLength {
+				return fmt.Sprintf("minified, %d-char line", len(line))
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratedReason(t *testing.T) {
+	tests := []struct {
+		name, path, content, want string
+	}{
+		{"plain source", "main.go", "package main\n", ""},
+		{"protobuf name", "api/v1/service.pb.go", "package v1\n", "name matches *.pb.go"},
+		{"lockfile", "web/package-lock.json", "{}\n", "name matches package-lock.json"},
+		{"minified name", "static/app.min.js", "x\n", "name matches *.min.js"},
+		{"go header", "zz_generated.go", "// Code generated by controller-gen. DO NOT EDIT.\n\npackage v1\n", "generated header comment"},
+		{"go header after license", "x.go", strings.Repeat("// License line.\n", 10) + "\n// Code generated by mockgen. DO NOT EDIT.\npackage x\n", "generated header comment"},
+		{"header past the first lines", "x.go", strings.Repeat("\n", generatedHeaderLines) + "// Code generated by tool. DO NOT EDIT.\n", ""},
+		{"@generated marker", "schema.py", "# @generated by schema tool\nx = 1\n", "generated header comment"},
+		{"block comment marker", "gen.js", "/* @generated */\nvar x = 1;\n", "generated header comment"},
+		{"mention in code", "notes.go", "package notes\n\nconst s = \"Code generated ... DO NOT EDIT.\"\n", ""},
+		{"minified content", "bundle.js", "var a=1;" + strings.Repeat("b()", minifiedLineLength) + "\n", "minified, 1508-char line"},
+		{"long line outside js/css", "data.txt", strings.Repeat("x", minifiedLineLength+1) + "\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generatedReason(tt.path, []byte(tt.content), nil); got != tt.want {
+				t.Errorf("generatedReason(%s) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratedReasonLanguagePatterns(t *testing.T) {
+	langData := &LoadedLanguageData{generated: []string{"*_gen.ts"}}
+	if got, want := generatedReason("src/api_gen.ts", []byte("export {}\n"), langData), "name matches *_gen.ts"; got != want {
+		t.Errorf("generatedReason() = %q, want %q", got, want)
+	}
+}
+
+func TestIsVendoredDir(t *testing.T) {
+	for name, want := range map[string]bool{"vendor": true, "third_party": true, "third-party": true, "bower_components": true, "node_modules": false, "vendors": false, "src": false} {
+		if got := isVendoredDir(name); got != want {
+			t.Errorf("isVendoredDir(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestProcessContentSkipsGenerated(t *testing.T) {
+	setFlag(t, &skipGenerated, true)
+	content := []byte("// Code generated by stringer. DO NOT EDIT.\n\npackage x\n")
+	file, emitted := processContent(FileInfo{Path: "t_string.go"}, content, byteTokenizer{})
+	if file.SkipReason != generatedSkipReason || emitted != nil {
+		t.Errorf("SkipReason = %q, content %q; want the generated file skipped", file.SkipReason, emitted)
+	}
+
+	setFlag(t, &skipGenerated, false)
+	if file, _ := processContent(FileInfo{Path: "t_string.go"}, content, byteTokenizer{}); file.SkipReason != "" {
+		t.Errorf("SkipReason = %q without --skip-generated", file.SkipReason)
+	}
+}
@@ -2,49 +2,332 @@ package main
 
 import (
 	"fmt"
+	neturl "net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
 )
 
-// isGitURL checks if the input string looks like a Git repository URL.
-// Prioritizes .git suffix or git@ prefix.
+// inputTreeRoots maps each Git URL or archive input to the directory its paths are
+// relative to ("." for a whole repository or archive), so a tree can be drawn for it.
+var inputTreeRoots = map[string]string{}
+
+// defaultGitHosts are the forges whose https links are cloned rather than fetched
+// as web pages. More can be added with --git-hosts for self-hosted forges.
+var defaultGitHosts = []string{"github.com", "gitlab.com"}
+
+// gitShorthands expands "github:org/repo" style inputs to the forge's https URL.
+var gitShorthands = map[string]string{
+	"github:": "https://github.com/",
+	"gitlab:": "https://gitlab.com/",
+}
+
+// forgePageSegments are path segments that end the repository part of a forge
+// link. Only "tree" (a directory at a ref) maps to something cloneable; links to
+// issues, blobs, commits and the like are left to the web fetcher.
+var forgePageSegments = map[string]bool{
+	"tree": true, "blob": true, "raw": true, "blame": true, "commit": true, "commits": true,
+	"issues": true, "pull": true, "pulls": true, "merge_requests": true, "compare": true,
+	"releases": true, "tags": true, "branches": true, "wiki": true, "wikis": true, "actions": true,
+}
+
+// isGitURL checks if the input string looks like a Git repository URL: a .git
+// suffix, git@ or ssh:// SSH URLs, github:/gitlab: shorthands and https links to
+// repositories on known forge hosts. A //subdir and a trailing #ref are allowed.
 func isGitURL(input string) bool {
-	// Check for common Git URL schemes and the .git suffix
+	input, _, _, _ = parseGitInput(input)
 	return strings.HasSuffix(input, ".git") ||
-		strings.HasPrefix(input, "git@") // Common SSH format
-	// Could add ssh:// but less common for direct user input
-	// Don't check for https:// or http:// by default as they are ambiguous
+		strings.HasPrefix(input, "git@") || // Common SSH format
+		strings.HasPrefix(input, "ssh://")
 }
 
-// cloneGitRepo clones a Git repository URL into a temporary directory.
-// It returns the path to the temporary directory or an error.
-func cloneGitRepo(url string) (string, error) {
+// parseGitInput splits "repo.git//path/to/subdir#ref" into the clone URL, the
+// slash-separated subdirectory and the ref (each "" if absent). Shorthands and
+// forge links are expanded to clone URLs first. The <ref>/<path> of a /tree/ link
+// is returned as treePath, to be split by splitTreePath, unless //subdir and #ref
+// are both given explicitly.
+func parseGitInput(input string) (url, subdir, ref, treePath string) {
+	if i := strings.LastIndex(input, "#"); i >= 0 {
+		input, ref = input[:i], input[i+1:]
+	}
+	for prefix, expansion := range gitShorthands {
+		if strings.HasPrefix(input, prefix) {
+			input = expansion + strings.TrimPrefix(input[len(prefix):], "/")
+			break
+		}
+	}
+	// Look for "//" after the scheme's "://" (if any)
+	searchFrom := 0
+	if i := strings.Index(input, "://"); i >= 0 {
+		searchFrom = i + len("://")
+	}
+	if i := strings.Index(input[searchFrom:], "//"); i >= 0 {
+		input, subdir = input[:searchFrom+i], input[searchFrom+i+len("//"):]
+	}
+	if repoURL, forgeTreePath, ok := parseForgeURL(input); ok {
+		input = repoURL
+		if subdir == "" || ref == "" {
+			treePath = forgeTreePath
+		}
+	}
+	return input, cleanGitSubdir(subdir), ref, treePath
+}
+
+// parseForgeURL recognises an http(s) link to a repository on a forge host, such
+// as https://github.com/org/repo or https://gitlab.com/group/sub/repo/-/tree/main/docs,
+// and returns its clone URL (ending in .git) and the <ref>/<path> part of a tree link.
+// Refs may contain slashes, so splitting that path needs the remote's refs.
+func parseForgeURL(input string) (repoURL, treePath string, ok bool) {
+	u, err := neturl.Parse(input)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || !isGitHost(u.Hostname()) {
+		return "", "", false
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	repoEnd := len(segments)
+	for i, segment := range segments {
+		if segment == "-" || forgePageSegments[segment] { // GitLab puts "/-/" before page paths
+			repoEnd = i
+			break
+		}
+	}
+	if repoEnd < 2 || segments[0] == "" {
+		return "", "", false // Users, organisations and site pages aren't repositories
+	}
+
+	page := segments[repoEnd:]
+	if len(page) > 0 && page[0] == "-" {
+		page = page[1:]
+	}
+	if len(page) > 0 {
+		if page[0] != "tree" || len(page) < 2 {
+			return "", "", false
+		}
+		treePath = strings.Join(page[1:], "/")
+	}
+
+	repoPath := strings.TrimSuffix(strings.Join(segments[:repoEnd], "/"), ".git")
+	return u.Scheme + "://" + u.Host + "/" + repoPath + ".git", treePath, true
+}
+
+// isGitHost reports whether host is a default or --git-hosts forge.
+func isGitHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, known := range append(append([]string(nil), defaultGitHosts...), parsePatterns(gitHosts)...) {
+		if host == strings.ToLower(known) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanGitSubdir normalises a repository subdirectory, returning "" for the root.
+func cleanGitSubdir(subdir string) string {
+	subdir = path.Clean("/" + strings.Trim(subdir, "/"))
+	return strings.TrimPrefix(subdir, "/")
+}
+
+// shaPattern matches full or abbreviated commit SHAs.
+var shaPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// cloneGitRepo clones a Git repository URL into a temporary directory, checking out
+// ref (a branch, tag or commit SHA) or the default branch when ref is empty.
+// Branches and tags are cloned shallow unless --since/--diff/--git-log/--blame-summary
+// need history. When
+// subdir is set only that directory is written to disk (sparse checkout).
+// Submodules are cloned too with --submodules include or recurse.
+// Unless --no-cache is set the repository is cloned from the local cache, which is
+// brought up to date first.
+// It returns the path to the temporary directory and the checked out commit SHA.
+func cloneGitRepo(url, ref, subdir string) (string, string, error) {
+	// Clone from the cached mirror if possible; messages still name the real URL
+	source := url
+	if !noCache {
+		cached, err := ensureCachedRepo(url)
+		if err != nil {
+			return "", "", err
+		}
+		source = cached
+	}
+
 	// Create a temporary directory
 	tempDir, err := os.MkdirTemp("", "iris-git-")
 	if err != nil {
-		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+		return "", "", fmt.Errorf("failed to create temporary directory: %w", err)
 	}
 
-	fmt.Printf("Cloning Git repository '%s' into '%s'...\n", url, tempDir)
-
-	// Clone the repository
-	_, err = git.PlainClone(tempDir, false, &git.CloneOptions{
-		URL:      url,
-		Progress: os.Stdout, // Show progress during clone
-		// Depth: 1, // Optional: shallow clone for faster download if history isn't needed
+	needHistory := changeBaseRef() != "" || gitHistoryRequested()
+	options := &git.CloneOptions{
+		URL:           source,
+		Progress:      logOut,        // Show progress during clone
 		ReferenceName: plumbing.HEAD, // Checkout default branch
-		SingleBranch:  true,          // Only fetch the default branch
-	})
+		SingleBranch:  !needHistory,  // Other branches may be the base of --since/--diff
+	}
+	if !needHistory {
+		options.Depth = 1 // Only the checked out commit is needed
+	}
+
+	checkoutRev := plumbing.Revision(plumbing.HEAD)
+	if ref != "" {
+		refName, err := resolveRemoteRef(source, url, ref)
+		if err != nil {
+			_ = os.RemoveAll(tempDir)
+			return "", "", err
+		}
+		if refName != "" {
+			options.ReferenceName = refName
+		} else {
+			// A commit SHA can't be fetched on its own, so fetch everything and check it out
+			options.SingleBranch = false
+			options.Depth = 0
+			options.NoCheckout = true
+			checkoutRev = plumbing.Revision(ref)
+		}
+	}
+	if subdir != "" {
+		options.NoCheckout = true // Checked out sparsely below
+	}
 
+	if ref != "" {
+		fmt.Fprintf(logOut, "Cloning Git repository '%s' at '%s' into '%s'...\n", url, ref, tempDir)
+	} else {
+		fmt.Fprintf(logOut, "Cloning Git repository '%s' into '%s'...\n", url, tempDir)
+	}
+
+	// Clone the repository
+	repo, err := git.PlainClone(tempDir, false, options)
 	if err != nil {
 		// Attempt cleanup even if clone failed
 		_ = os.RemoveAll(tempDir)
-		return "", fmt.Errorf("failed to clone repository '%s': %w", url, err)
+		return "", "", fmt.Errorf("failed to clone repository '%s': %w", url, err)
+	}
+
+	if options.NoCheckout {
+		hash, err := repo.ResolveRevision(checkoutRev)
+		if err != nil {
+			_ = os.RemoveAll(tempDir)
+			return "", "", fmt.Errorf("ref '%s' not found in '%s': %w", checkoutRev, url, err)
+		}
+		checkout := &git.CheckoutOptions{Hash: *hash, Force: true}
+		if subdir != "" {
+			checkout.SparseCheckoutDirectories = []string{subdir}
+		}
+		worktree, err := repo.Worktree()
+		if err == nil {
+			err = worktree.Checkout(checkout)
+		}
+		if err != nil {
+			_ = os.RemoveAll(tempDir)
+			return "", "", fmt.Errorf("failed to check out %s: %w", checkoutRev, err)
+		}
 	}
 
-	fmt.Printf("Finished cloning '%s'.\n", url)
-	return tempDir, nil
+	if subdir != "" {
+		if info, err := os.Stat(filepath.Join(tempDir, filepath.FromSlash(subdir))); err != nil || !info.IsDir() {
+			_ = os.RemoveAll(tempDir)
+			return "", "", fmt.Errorf("subdirectory '%s' not found in '%s'", subdir, url)
+		}
+	}
+
+	if submoduleMode != "skip" {
+		if err := initClonedSubmodules(repo, url, subdir); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not initialize submodules of '%s': %v\n", url, err)
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		_ = os.RemoveAll(tempDir)
+		return "", "", fmt.Errorf("failed to resolve HEAD of '%s': %w", url, err)
+	}
+
+	fmt.Fprintf(logOut, "Finished cloning '%s' (commit %s).\n", url, head.Hash())
+	return tempDir, head.Hash().String(), nil
+}
+
+// relocateClonedFiles makes the paths of files found in a clone relative to the
+// repository root for display, keeping the temporary location for reading.
+func relocateClonedFiles(files []FileInfo, cloneDir string) {
+	for i := range files {
+		rel, err := filepath.Rel(cloneDir, files[i].Path)
+		if err != nil {
+			continue
+		}
+		files[i].DiskPath = files[i].Path
+		files[i].Path = rel
+	}
+}
+
+// resolveRemoteRef looks ref up on the remote and returns the branch or tag it names.
+// It returns an empty name (and no error) when ref is a commit SHA instead.
+// The refs are listed from source (the remote or its cached mirror); url names it in errors.
+func resolveRemoteRef(source, url, ref string) (plumbing.ReferenceName, error) {
+	refs, err := listRemoteRefs(source, url)
+	if err != nil {
+		return "", err
+	}
+	if name := findRemoteRef(refs, ref); name != "" {
+		return name, nil
+	}
+	if shaPattern.MatchString(ref) {
+		return "", nil
+	}
+	return "", fmt.Errorf("ref '%s' is not a branch, tag or commit SHA of '%s'", ref, url)
+}
+
+// splitTreePath splits the <ref>/<path> of a forge tree link into the ref and the
+// subdirectory, taking the longest leading path that names a branch or tag of url,
+// so "feature/x/docs" is ref "feature/x" and directory "docs" when that branch
+// exists. Failing that, the first segment must be a commit SHA.
+func splitTreePath(url, treePath string) (ref, subdir string, err error) {
+	segments := strings.Split(treePath, "/")
+	if len(segments) == 1 {
+		return treePath, "", nil // Nothing to split; the ref is checked when cloning
+	}
+	refs, err := listRemoteRefs(url, url)
+	if err != nil {
+		return "", "", err
+	}
+	for n := len(segments); n > 0; n-- {
+		if candidate := strings.Join(segments[:n], "/"); findRemoteRef(refs, candidate) != "" {
+			return candidate, cleanGitSubdir(strings.Join(segments[n:], "/")), nil
+		}
+	}
+	if shaPattern.MatchString(segments[0]) {
+		return segments[0], cleanGitSubdir(strings.Join(segments[1:], "/")), nil
+	}
+	return "", "", fmt.Errorf("tree link path '%s' does not start with a branch, tag or commit SHA of '%s'", treePath, url)
+}
+
+// listRemoteRefs lists the refs of source (a remote or its cached mirror); url names it in errors.
+func listRemoteRefs(source, url string) ([]*plumbing.Reference, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{source}})
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs of '%s': %w", url, err)
+	}
+	return refs, nil
+}
+
+// findRemoteRef returns the branch or tag among refs that ref names, or "" if none does.
+func findRemoteRef(refs []*plumbing.Reference, ref string) plumbing.ReferenceName {
+	candidates := []plumbing.ReferenceName{
+		plumbing.ReferenceName(ref),
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewTagReferenceName(ref),
+	}
+	for _, candidate := range candidates {
+		for _, remoteRef := range refs {
+			if remoteRef.Name() == candidate && (candidate.IsBranch() || candidate.IsTag()) {
+				return candidate
+			}
+		}
+	}
+	return ""
 }
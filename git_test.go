@@ -0,0 +1,100 @@
+package main
+
+import "testing"
+
+func TestParseGitInput(t *testing.T) {
+	tests := []struct {
+		input                      string
+		url, subdir, ref, treePath string
+	}{
+		{"https://example.com/org/repo.git", "https://example.com/org/repo.git", "", "", ""},
+		{"https://example.com/org/repo.git#v1.2.0", "https://example.com/org/repo.git", "", "v1.2.0", ""},
+		{"https://example.com/org/repo.git//docs/api#main", "https://example.com/org/repo.git", "docs/api", "main", ""},
+		{"https://example.com/org/repo.git//docs/../src/", "https://example.com/org/repo.git", "src", "", ""},
+		{"https://example.com/org/repo.git//", "https://example.com/org/repo.git", "", "", ""},
+		{"git@github.com:org/repo.git//pkg", "git@github.com:org/repo.git", "pkg", "", ""},
+		{"ssh://git@example.com/org/repo.git//pkg#abc1234", "ssh://git@example.com/org/repo.git", "pkg", "abc1234", ""},
+		{"github:org/repo", "https://github.com/org/repo.git", "", "", ""},
+		{"github:/org/repo#dev", "https://github.com/org/repo.git", "", "dev", ""},
+		{"gitlab:group/sub/repo//lib", "https://gitlab.com/group/sub/repo.git", "lib", "", ""},
+		{"https://github.com/org/repo/tree/main/docs", "https://github.com/org/repo.git", "", "", "main/docs"},
+		{"https://github.com/org/repo/tree/feature/x/docs#v2", "https://github.com/org/repo.git", "", "v2", "feature/x/docs"},
+		// Explicit //subdir and #ref together leave nothing for a tree link to supply
+		{"https://github.com/org/repo/tree/main/docs//src#v2", "https://github.com/org/repo.git", "src", "v2", ""},
+		{"https://example.com/page", "https://example.com/page", "", "", ""},
+	}
+	for _, tt := range tests {
+		url, subdir, ref, treePath := parseGitInput(tt.input)
+		if url != tt.url || subdir != tt.subdir || ref != tt.ref || treePath != tt.treePath {
+			t.Errorf("parseGitInput(%q) = %q, %q, %q, %q; want %q, %q, %q, %q",
+				tt.input, url, subdir, ref, treePath, tt.url, tt.subdir, tt.ref, tt.treePath)
+		}
+	}
+}
+
+func TestParseForgeURL(t *testing.T) {
+	tests := []struct {
+		input    string
+		repoURL  string
+		treePath string
+		ok       bool
+	}{
+		{"https://github.com/org/repo", "https://github.com/org/repo.git", "", true},
+		{"https://github.com/org/repo.git", "https://github.com/org/repo.git", "", true},
+		{"https://github.com/org/repo/", "https://github.com/org/repo.git", "", true},
+		{"http://GitHub.com/org/repo", "http://GitHub.com/org/repo.git", "", true},
+		{"https://github.com/org/repo/tree/main", "https://github.com/org/repo.git", "main", true},
+		{"https://github.com/org/repo/tree/v1/cmd/tool", "https://github.com/org/repo.git", "v1/cmd/tool", true},
+		{"https://github.com/org/repo/tree/feature/x/docs/", "https://github.com/org/repo.git", "feature/x/docs", true},
+		{"https://gitlab.com/group/sub/repo", "https://gitlab.com/group/sub/repo.git", "", true},
+		{"https://gitlab.com/group/sub/repo/-/tree/main/docs", "https://gitlab.com/group/sub/repo.git", "main/docs", true},
+		{"https://github.com/org/repo/blob/main/README.md", "", "", false},
+		{"https://github.com/org/repo/issues/1", "", "", false},
+		{"https://github.com/org/repo/tree", "", "", false},
+		{"https://github.com/org", "", "", false},
+		{"https://github.com/", "", "", false},
+		{"https://example.com/org/repo", "", "", false},
+		{"ftp://github.com/org/repo", "", "", false},
+		{"github:org/repo", "", "", false},
+	}
+	for _, tt := range tests {
+		repoURL, treePath, ok := parseForgeURL(tt.input)
+		if repoURL != tt.repoURL || treePath != tt.treePath || ok != tt.ok {
+			t.Errorf("parseForgeURL(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.input, repoURL, treePath, ok, tt.repoURL, tt.treePath, tt.ok)
+		}
+	}
+}
+
+func TestParseForgeURLCustomHosts(t *testing.T) {
+	oldHosts := gitHosts
+	gitHosts = "git.example.com"
+	t.Cleanup(func() { gitHosts = oldHosts })
+
+	repoURL, _, ok := parseForgeURL("https://git.example.com/team/repo")
+	if !ok || repoURL != "https://git.example.com/team/repo.git" {
+		t.Errorf("parseForgeURL with --git-hosts = %q, %v", repoURL, ok)
+	}
+}
+
+func TestIsGitURL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"https://example.com/org/repo.git", true},
+		{"https://example.com/org/repo.git//docs#main", true},
+		{"git@github.com:org/repo.git", true},
+		{"ssh://git@example.com/org/repo", true},
+		{"github:org/repo", true},
+		{"https://github.com/org/repo", true},
+		{"https://github.com/org/repo/blob/main/README.md", false},
+		{"https://example.com/docs", false},
+		{"./local/dir", false},
+	}
+	for _, tt := range tests {
+		if got := isGitURL(tt.input); got != tt.want {
+			t.Errorf("isGitURL(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// matchesAnyPattern checks if relPath (slash-separated, relative to the input root)
+// matches any of the provided glob patterns.
+//
+// Patterns without a slash (e.g. "*.go") match the base name at any depth, as they
+// always have. Patterns with a slash are matched against the whole relative path,
+// where "**" matches zero or more directories (e.g. "internal/**/testdata/*").
+// For directories, a pattern ending in "/**" also matches the directory itself, so
+// "**/node_modules/**" prunes node_modules before it is traversed.
+// A trailing slash (e.g. "build/") restricts a pattern to directories, as in
+// .gitignore; a file matches it when one of the directories containing it does.
+func matchesAnyPattern(relPath string, patterns []string, isDir bool) (bool, error) {
+	relPath = strings.TrimPrefix(relPath, "./")
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(pattern, "./")
+		if err := validateGlob(pattern); err != nil {
+			return false, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+		}
+
+		if dirPattern, ok := strings.CutSuffix(pattern, "/"); ok && dirPattern != "" {
+			if isDir && matchPattern(dirPattern, relPath, true) {
+				return true, nil
+			}
+			for dir := path.Dir(relPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+				if matchPattern(dirPattern, dir, true) {
+					return true, nil
+				}
+			}
+			continue
+		}
+		if matchPattern(pattern, relPath, isDir) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchPattern matches a single pattern as described for matchesAnyPattern.
+func matchPattern(pattern, relPath string, isDir bool) bool {
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(relPath))
+		return matched
+	}
+	pattern = strings.TrimPrefix(pattern, "/") // A leading slash anchors at the root, which is implied
+	if globMatch(pattern, relPath) {
+		return true
+	}
+	return isDir && strings.HasSuffix(pattern, "/**") && globMatch(strings.TrimSuffix(pattern, "/**"), relPath)
+}
+
+// validateGlob reports a malformed segment (e.g. an unclosed '[') in pattern.
+func validateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// globMatch matches a slash-separated path against a pattern supporting "**" segments.
+func globMatch(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments one by one; "**" may consume any number of them.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(rest, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestMatchesAnyPattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		relPath  string
+		patterns []string
+		isDir    bool
+		want     bool
+	}{
+		{"basename at root", "main.go", []string{"*.go"}, false, true},
+		{"basename at depth", "cmd/iris/main.go", []string{"*.go"}, false, true},
+		{"basename mismatch", "README.md", []string{"*.go"}, false, false},
+		{"leading dot slash", "./docs/a.md", []string{"./docs/*.md"}, false, true},
+		{"anchored path", "docs/a.md", []string{"/docs/*.md"}, false, true},
+		{"slash pattern needs full path", "src/docs/a.md", []string{"docs/*.md"}, false, false},
+		{"double star zero dirs", "internal/testdata/x.txt", []string{"internal/**/testdata/*"}, false, true},
+		{"double star many dirs", "internal/a/b/testdata/x.txt", []string{"internal/**/testdata/*"}, false, true},
+		{"double star prefix", "a/b/node_modules/pkg/index.js", []string{"**/node_modules/**"}, false, true},
+		{"trailing double star matches dir", "web/node_modules", []string{"**/node_modules/**"}, true, true},
+		{"any of several", "x.rs", []string{"*.go", "*.rs"}, false, true},
+		{"trailing slash matches a directory", "web/build", []string{"build/"}, true, true},
+		{"trailing slash skips a file", "web/build", []string{"build/"}, false, false},
+		{"trailing slash matches files inside", "build/out/app.js", []string{"build/"}, false, true},
+		{"trailing slash basename at depth", "web/build/app.js", []string{"build/"}, false, true},
+		{"trailing slash glob", "tmp-cache", []string{"tmp-*/"}, true, true},
+		{"anchored trailing slash", "build", []string{"/build/"}, true, true},
+		{"anchored trailing slash not nested", "web/build/app.js", []string{"/build/"}, false, false},
+		{"slash pattern with trailing slash", "docs/api/index.md", []string{"docs/api/"}, false, true},
+		{"no patterns", "main.go", nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchesAnyPattern(tt.relPath, tt.patterns, tt.isDir)
+			if err != nil {
+				t.Fatalf("matchesAnyPattern(%q, %q) error: %v", tt.relPath, tt.patterns, err)
+			}
+			if got != tt.want {
+				t.Errorf("matchesAnyPattern(%q, %q, %v) = %v, want %v", tt.relPath, tt.patterns, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesAnyPatternInvalid(t *testing.T) {
+	for _, pattern := range []string{"[", "src/[a-/*.go"} {
+		if _, err := matchesAnyPattern("main.go", []string{pattern}, false); err == nil {
+			t.Errorf("matchesAnyPattern with %q: expected an error", pattern)
+		}
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/*", "a/b", true},
+		{"a/*", "a/b/c", false},
+		{"**", "a/b/c", true},
+		{"**/c", "c", true},
+		{"**/c", "a/b/c", true},
+		{"a/**/c", "a/c", true},
+		{"a/**/c", "a/x/y/c", true},
+		{"a/**/c", "a/x/y/d", false},
+		{"a/**", "a", true},
+		{"*.go", "dir/main.go", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// validGitLogScopes lists the values accepted by --git-log-scope.
+var validGitLogScopes = []string{"file", "repo"}
+
+// validGitContextFormats lists the output formats --git-context-formats can name.
+var validGitContextFormats = []string{"text", "xml", "markdown", "json", "jsonl", "pdf"}
+
+// blameTopAuthors is how many authors --blame-summary lists per file.
+const blameTopAuthors = 3
+
+// gitContextDateFormat is the date layout used for commits and last-modified dates.
+const gitContextDateFormat = "2006-01-02"
+
+// gitCloneURLs maps the temporary directory of each cloned Git URL to the URL,
+// so repository-wide history is labelled with the URL instead of the temp path.
+var gitCloneURLs = map[string]string{}
+
+// gitHistoryRequested reports whether --git-log or --blame-summary asked for history,
+// which means cloned repositories can't be shallow.
+func gitHistoryRequested() bool {
+	return gitLogCount > 0 || blameSummary
+}
+
+// gitContextEnabled reports whether history and blame sections are added to the
+// current output format. --git-context-formats limits them to some formats.
+func gitContextEnabled() bool {
+	if !gitHistoryRequested() {
+		return false
+	}
+	formats := parsePatterns(gitContextFormats)
+	return len(formats) == 0 || containsString(formats, currentOutputKind())
+}
+
+// currentOutputKind names the renderer that will produce the output, as used by --git-context-formats.
+func currentOutputKind() string {
+	switch {
+	case pdfOutputFile != "":
+		return "pdf"
+	case resultFormat == "json" || resultFormat == "jsonl":
+		return resultFormat
+	case outputFormat == "xml" || outputFormat == "markdown":
+		return outputFormat
+	}
+	return "text"
+}
+
+// gitContextRepo is a repository opened while collecting history, shared by its files.
+type gitContextRepo struct {
+	repo  *git.Repository
+	root  string // Absolute worktree root
+	label string // Name shown in repository-wide history
+	head  *object.Commit
+}
+
+// addGitContext attaches --git-log history and --blame-summary annotations to the
+// emitted files of git repositories and adds their tokens to each file's count.
+// With --git-log-scope repo it returns the recent history of each repository
+// instead. It runs sequentially because go-git storage isn't safe for concurrent use.
+func addGitContext(files []FileInfo, tk Tokenizer) []RepoHistory {
+	repos := make(map[string]*gitContextRepo) // Directory -> repository, nil outside git
+	var touched []*gitContextRepo
+
+	for i := range files {
+		file := &files[i]
+		if file.IsDir || file.SkipReason != "" || file.Archive != "" || file.Stdin || isWebURL(file.Path) {
+			continue
+		}
+		absPath, err := filepath.Abs(file.GitPath())
+		if err != nil {
+			continue
+		}
+		ctx := openGitContextRepo(filepath.Dir(absPath), repos)
+		if ctx == nil || ctx.head == nil {
+			continue
+		}
+		if !containsRepo(touched, ctx) {
+			touched = append(touched, ctx)
+		}
+		rel, err := filepath.Rel(ctx.root, absPath)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		if gitLogCount > 0 && gitLogScope == "file" {
+			file.History, err = recentCommits(ctx.repo, ctx.head, rel, gitLogCount)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not read git log for %s: %v\n", file.Path, err)
+			}
+		}
+		if blameSummary {
+			file.Blame, err = summarizeBlame(ctx.head, rel)
+			if err != nil && !errors.Is(err, object.ErrFileNotFound) { // Untracked files have no blame
+				fmt.Fprintf(os.Stderr, "Warning: could not blame %s: %v\n", file.Path, err)
+			}
+		}
+
+		if tk != nil && !disableTokens && file.Error == nil {
+			if extra := formatGitContext(*file); extra != "" {
+				tokens := tk.CountTokens(extra)
+				file.TokenCount += tokens
+				if file.OriginalTokenCount > 0 {
+					file.OriginalTokenCount += tokens
+				}
+			}
+		}
+	}
+
+	if gitLogCount == 0 || gitLogScope != "repo" {
+		return nil
+	}
+	var histories []RepoHistory
+	for _, ctx := range touched {
+		commits, err := recentCommits(ctx.repo, ctx.head, "", gitLogCount)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not read git log for %s: %v\n", ctx.label, err)
+			continue
+		}
+		histories = append(histories, RepoHistory{Repository: ctx.label, Commits: commits})
+	}
+	return histories
+}
+
+// openGitContextRepo finds the repository containing dir, caching the result per directory.
+func openGitContextRepo(dir string, repos map[string]*gitContextRepo) *gitContextRepo {
+	if ctx, ok := repos[dir]; ok {
+		return ctx
+	}
+	repos[dir] = nil
+
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil
+	}
+	root, err := filepath.Abs(worktree.Filesystem.Root())
+	if err != nil {
+		return nil
+	}
+	// Reuse the repository when another directory of it was seen first
+	for _, ctx := range repos {
+		if ctx != nil && ctx.root == root {
+			repos[dir] = ctx
+			return ctx
+		}
+	}
+
+	ctx := &gitContextRepo{repo: repo, root: root, label: gitRepoLabel(root)}
+	if head, err := repo.Head(); err == nil {
+		ctx.head, _ = repo.CommitObject(head.Hash())
+	}
+	repos[dir] = ctx
+	return ctx
+}
+
+// gitRepoLabel names a repository by its clone URL, or its path relative to the working directory.
+func gitRepoLabel(root string) string {
+	for dir, url := range gitCloneURLs {
+		if absDir, err := filepath.Abs(dir); err == nil && absDir == root {
+			return url
+		}
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, root); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return root
+}
+
+// containsRepo reports whether repos already holds ctx.
+func containsRepo(repos []*gitContextRepo, ctx *gitContextRepo) bool {
+	for _, r := range repos {
+		if r == ctx {
+			return true
+		}
+	}
+	return false
+}
+
+// recentCommits returns up to n commits reachable from head, newest first. When path
+// is set only commits touching that file are returned.
+func recentCommits(repo *git.Repository, head *object.Commit, path string, n int) ([]CommitInfo, error) {
+	options := &git.LogOptions{From: head.Hash, Order: git.LogOrderCommitterTime}
+	if path != "" {
+		options.FileName = &path
+	}
+	iter, err := repo.Log(options)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var commits []CommitInfo
+	err = iter.ForEach(func(c *object.Commit) error {
+		commits = append(commits, CommitInfo{
+			Hash:    c.Hash.String()[:7],
+			Author:  c.Author.Name,
+			Date:    c.Author.When,
+			Message: strings.TrimSpace(c.Message),
+		})
+		if len(commits) >= n {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return commits, err
+}
+
+// summarizeBlame blames path at head and returns its top authors by line count and
+// the date of its most recently changed line.
+func summarizeBlame(head *object.Commit, path string) (*BlameSummary, error) {
+	result, err := git.Blame(head, path)
+	if err != nil {
+		return nil, err
+	}
+	summary := &BlameSummary{}
+	lines := make(map[string]int)
+	for _, line := range result.Lines {
+		author := line.AuthorName
+		if author == "" {
+			author = line.Author
+		}
+		lines[author]++
+		if line.Date.After(summary.LastModified) {
+			summary.LastModified = line.Date
+			summary.LastAuthor = author
+		}
+	}
+	for name, count := range lines {
+		summary.Authors = append(summary.Authors, AuthorLines{Name: name, Lines: count})
+	}
+	sort.Slice(summary.Authors, func(i, j int) bool {
+		a, b := summary.Authors[i], summary.Authors[j]
+		if a.Lines != b.Lines {
+			return a.Lines > b.Lines
+		}
+		return a.Name < b.Name
+	})
+	summary.TotalLines = len(result.Lines)
+	if len(summary.Authors) > blameTopAuthors {
+		summary.Authors = summary.Authors[:blameTopAuthors]
+	}
+	return summary, nil
+}
+
+// formatBlameSummary renders "Alice (60%), Bob (40%); last modified 2026-01-02 by Alice".
+func formatBlameSummary(blame *BlameSummary) string {
+	parts := make([]string, len(blame.Authors))
+	for i, author := range blame.Authors {
+		percent := 0
+		if blame.TotalLines > 0 {
+			percent = author.Lines * 100 / blame.TotalLines
+		}
+		parts[i] = fmt.Sprintf("%s (%d%%)", author.Name, percent)
+	}
+	text := strings.Join(parts, ", ")
+	if !blame.LastModified.IsZero() {
+		text += fmt.Sprintf("; last modified %s by %s", blame.LastModified.Format(gitContextDateFormat), blame.LastAuthor)
+	}
+	return text
+}
+
+// formatCommits renders commits as "abc1234 2026-01-02 Alice: subject" lines, with
+// the rest of each message indented below, each line prefixed by indent.
+func formatCommits(commits []CommitInfo, indent string) string {
+	var builder strings.Builder
+	for _, commit := range commits {
+		subject, body, _ := strings.Cut(commit.Message, "\n")
+		builder.WriteString(fmt.Sprintf("%s%s %s %s: %s\n", indent, commit.Hash, commit.Date.Format(gitContextDateFormat), commit.Author, subject))
+		for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+			if line = strings.TrimRight(line, " \t"); line != "" {
+				builder.WriteString(indent + "    " + line + "\n")
+			}
+		}
+	}
+	return builder.String()
+}
+
+// formatGitContext renders a file's blame summary and history as the plain-text
+// lines shown under its header. It returns "" when there is neither.
+func formatGitContext(file FileInfo) string {
+	var builder strings.Builder
+	if file.Blame != nil && len(file.Blame.Authors) > 0 {
+		builder.WriteString("Authors: " + formatBlameSummary(file.Blame) + "\n")
+	}
+	if len(file.History) > 0 {
+		builder.WriteString("Recent commits:\n")
+		builder.WriteString(formatCommits(file.History, "  "))
+	}
+	return builder.String()
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// historyTestRepo commits main.go as four authors and other.go in between, and
+// returns the repository and the commit SHAs, oldest first.
+func historyTestRepo(t *testing.T) (*testRepo, []string) {
+	t.Helper()
+	repo := newTestRepo(t)
+	repo.write(t, "main.go", "a\nb\nc\nd\n")
+	repo.write(t, "other.go", "package other\n")
+	shas := []string{repo.commitAs(t, "Alice", "Add main")}
+	repo.write(t, "main.go", "a\nB\nc\nd\ne\n")
+	shas = append(shas, repo.commitAs(t, "Bob", "Fix main\n\nReplace b and add e.\n"))
+	repo.write(t, "other.go", "package other\n\nvar x = 1\n")
+	shas = append(shas, repo.commitAs(t, "Carol", "Touch other"))
+	repo.write(t, "main.go", "a\nB\nc\nd\ne\nf\n")
+	shas = append(shas, repo.commitAs(t, "Dave", "Add f"))
+	repo.write(t, "main.go", "a\nB\nc\nd\ne\nf\ng\n")
+	shas = append(shas, repo.commitAs(t, "Erin", "Add g"))
+	return repo, shas
+}
+
+// headCommit returns the commit HEAD of repo points to.
+func headCommit(t *testing.T, repo *testRepo) *object.Commit {
+	t.Helper()
+	head, err := repo.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit
+}
+
+func TestRecentCommits(t *testing.T) {
+	repo, shas := historyTestRepo(t)
+	head := headCommit(t, repo)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		path string
+		n    int
+		want []int // Indexes into shas, newest first
+	}{
+		{"main.go", 10, []int{4, 3, 1, 0}},
+		{"main.go", 2, []int{4, 3}},
+		{"other.go", 10, []int{2, 0}},
+		{"", 10, []int{4, 3, 2, 1, 0}},
+		{"", 1, []int{4}},
+		{"missing.go", 10, nil},
+	}
+	authors := []string{"Alice", "Bob", "Carol", "Dave", "Erin"}
+	messages := []string{"Add main", "Fix main\n\nReplace b and add e.", "Touch other", "Add f", "Add g"}
+	for _, tt := range tests {
+		commits, err := recentCommits(repo.repo, head, tt.path, tt.n)
+		if err != nil {
+			t.Fatalf("recentCommits(%q, %d): %v", tt.path, tt.n, err)
+		}
+		var want []CommitInfo
+		for _, i := range tt.want {
+			want = append(want, CommitInfo{
+				Hash:    shas[i][:7],
+				Author:  authors[i],
+				Date:    start.Add(time.Duration(i) * time.Hour),
+				Message: messages[i],
+			})
+		}
+		for i := range commits {
+			commits[i].Date = commits[i].Date.UTC()
+		}
+		if !reflect.DeepEqual(commits, want) {
+			t.Errorf("recentCommits(%q, %d) = %+v, want %+v", tt.path, tt.n, commits, want)
+		}
+	}
+}
+
+func TestSummarizeBlame(t *testing.T) {
+	repo, _ := historyTestRepo(t)
+	head := headCommit(t, repo)
+
+	blame, err := summarizeBlame(head, "main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Dave and Erin tie on one line each; only the top three authors are kept
+	want := &BlameSummary{
+		Authors:      []AuthorLines{{"Alice", 3}, {"Bob", 2}, {"Dave", 1}},
+		TotalLines:   7,
+		LastModified: time.Date(2024, 1, 1, 16, 0, 0, 0, time.UTC),
+		LastAuthor:   "Erin",
+	}
+	blame.LastModified = blame.LastModified.UTC()
+	if !reflect.DeepEqual(blame, want) {
+		t.Errorf("summarizeBlame(main.go) = %+v, want %+v", blame, want)
+	}
+
+	if _, err := summarizeBlame(head, "untracked.go"); !errors.Is(err, object.ErrFileNotFound) {
+		t.Errorf("summarizeBlame(untracked.go) error = %v, want %v", err, object.ErrFileNotFound)
+	}
+}
+
+func TestFormatGitContext(t *testing.T) {
+	file := FileInfo{
+		Blame: &BlameSummary{
+			Authors:      []AuthorLines{{"Alice", 3}, {"Bob", 2}, {"Dave", 1}},
+			TotalLines:   7,
+			LastModified: time.Date(2024, 1, 1, 16, 0, 0, 0, time.UTC),
+			LastAuthor:   "Erin",
+		},
+		History: []CommitInfo{
+			{Hash: "1111111", Author: "Erin", Date: time.Date(2024, 1, 1, 16, 0, 0, 0, time.UTC), Message: "Add g"},
+			{Hash: "2222222", Author: "Bob", Date: time.Date(2023, 12, 31, 9, 0, 0, 0, time.UTC), Message: "Fix main\n\nReplace b  \n\nand add e."},
+		},
+	}
+	const wantBlame = "Authors: Alice (42%), Bob (28%), Dave (14%); last modified 2024-01-01 by Erin\n"
+	const wantHistory = "Recent commits:\n" +
+		"  1111111 2024-01-01 Erin: Add g\n" +
+		"  2222222 2023-12-31 Bob: Fix main\n" +
+		"      Replace b\n" +
+		"      and add e.\n"
+	if got := formatGitContext(file); got != wantBlame+wantHistory {
+		t.Errorf("formatGitContext() =\n%s\nwant\n%s", got, wantBlame+wantHistory)
+	}
+
+	// Either part alone, and neither
+	historyOnly := file
+	historyOnly.Blame = nil
+	if got := formatGitContext(historyOnly); got != wantHistory {
+		t.Errorf("formatGitContext() without blame =\n%s\nwant\n%s", got, wantHistory)
+	}
+	blameOnly := file
+	blameOnly.History = nil
+	if got := formatGitContext(blameOnly); got != wantBlame {
+		t.Errorf("formatGitContext() without history =\n%s\nwant\n%s", got, wantBlame)
+	}
+	if got := formatGitContext(FileInfo{Blame: &BlameSummary{}}); got != "" {
+		t.Errorf("formatGitContext() of an empty blame = %q, want \"\"", got)
+	}
+}
+
+func TestAddGitContext(t *testing.T) {
+	repo, shas := historyTestRepo(t)
+	repo.write(t, "untracked.go", "package untracked\n")
+	t.Chdir(repo.dir)
+	setFlag(t, &gitLogCount, 2)
+	setFlag(t, &gitLogScope, "file")
+	setFlag(t, &blameSummary, true)
+	setFlag(t, &disableTokens, false)
+
+	files := []FileInfo{
+		{Path: repo.path("main.go"), TokenCount: 10},
+		{Path: repo.path("untracked.go"), TokenCount: 10},
+		{Path: repo.path("other.go"), SkipReason: "skipped"},
+	}
+	if histories := addGitContext(files, byteTokenizer{}); histories != nil {
+		t.Errorf("file scope returned repository histories %v", histories)
+	}
+	mainFile := files[0]
+	if len(mainFile.History) != 2 || mainFile.History[0].Hash != shas[4][:7] || mainFile.Blame == nil || mainFile.Blame.LastAuthor != "Erin" {
+		t.Errorf("main.go history = %+v, blame = %+v", mainFile.History, mainFile.Blame)
+	}
+	if want := 10 + len(formatGitContext(mainFile)); mainFile.TokenCount != want {
+		t.Errorf("main.go tokens = %d, want %d with the git context", mainFile.TokenCount, want)
+	}
+	if untracked := files[1]; len(untracked.History) != 0 || untracked.Blame != nil || untracked.TokenCount != 10 {
+		t.Errorf("untracked.go got git context: %+v", untracked)
+	}
+	if skipped := files[2]; skipped.History != nil || skipped.Blame != nil {
+		t.Errorf("skipped file got git context: %+v", skipped)
+	}
+
+	// Repository scope lists the history once instead of per file
+	setFlag(t, &gitLogScope, "repo")
+	setFlag(t, &blameSummary, false)
+	files = []FileInfo{{Path: repo.path("main.go")}, {Path: repo.path("other.go")}}
+	histories := addGitContext(files, byteTokenizer{})
+	if len(histories) != 1 || histories[0].Repository != "." || len(histories[0].Commits) != 2 ||
+		histories[0].Commits[1].Hash != shas[3][:7] {
+		t.Errorf("repository histories = %+v", histories)
+	}
+	if files[0].History != nil {
+		t.Errorf("repo scope attached history to main.go: %v", files[0].History)
+	}
+}
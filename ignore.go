@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// gitIgnoreFileName is git's per-directory ignore file.
+const gitIgnoreFileName = ".gitignore"
+
+// irisIgnoreFileName is Iris' own per-directory ignore file (gitignore syntax).
+// Unlike .gitignore it is honored even with --no-ignore.
+const irisIgnoreFileName = ".irisignore"
+
+// ignoreRules evaluates git-compatible ignore rules (nested .gitignore files,
+// .git/info/exclude, the user's global excludes file and .irisignore files) for
+// paths below root.
+// Patterns are anchored at base, the enclosing repository's worktree root (or
+// root itself outside a repository), so directory-scoped rules and negations
+// behave exactly as in git. A nil *ignoreRules ignores nothing.
+type ignoreRules struct {
+	root     string   // Directory the caller walks, as given by the caller
+	useGit   bool     // Whether git's ignore sources are read, or only .irisignore
+	rootPath []string // Components of root relative to base
+	fsys     fs.FS    // File system rooted at base that per-directory ignore files are read from
+	patterns []gitignore.Pattern
+	matcher  gitignore.Matcher
+	loaded   map[string]bool // Directories (relative to base) whose ignore files were read
+}
+
+// newIgnoreRules prepares the rules for walking root: global excludes, the
+// repository's info/exclude and every ignore file from base down to root.
+// Ignore files inside root are loaded lazily through loadDir while walking.
+// When useGit is false only .irisignore files are read.
+func newIgnoreRules(root string, useGit bool) *ignoreRules {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil
+	}
+	base := absRoot
+	gitRoot, inRepo := findGitRoot(absRoot)
+	if inRepo {
+		base = gitRoot
+	}
+	relRoot, err := filepath.Rel(base, absRoot)
+	if err != nil {
+		return nil
+	}
+
+	r := &ignoreRules{root: root, useGit: useGit, rootPath: splitPath(relRoot), fsys: os.DirFS(base), loaded: make(map[string]bool)}
+
+	// Lowest priority first: system and global excludes, then info/exclude
+	if useGit {
+		rootFS := osfs.New("/")
+		if ps, err := gitignore.LoadSystemPatterns(rootFS); err == nil {
+			r.patterns = append(r.patterns, ps...)
+		}
+		if ps, err := gitignore.LoadGlobalPatterns(rootFS); err == nil && len(ps) > 0 {
+			r.patterns = append(r.patterns, ps...)
+		} else {
+			// git falls back to $XDG_CONFIG_HOME/git/ignore when core.excludesFile is unset
+			r.patterns = append(r.patterns, readIgnoreFile(defaultGlobalIgnoreFile(), nil)...)
+		}
+		if inRepo {
+			r.patterns = append(r.patterns, readIgnoreFile(filepath.Join(gitCommonDir(base), "info", "exclude"), nil)...)
+		}
+	}
+
+	// Ignore files of the directories above root still apply inside it
+	for i := 0; i < len(r.rootPath); i++ {
+		domain := r.rootPath[:i]
+		r.loadDomain(domain)
+	}
+	r.loadDir(root)
+	return r
+}
+
+// loadDir reads the ignore files in dir, a directory at or below root.
+// It must be called before the contents of dir are matched.
+func (r *ignoreRules) loadDir(dir string) {
+	if r == nil {
+		return
+	}
+	domain, ok := r.relComponents(dir)
+	if !ok {
+		return
+	}
+	r.loadDomain(domain)
+}
+
+// loadDomain reads the ignore files of the directory at domain (components
+// relative to base), scoping their patterns to it.
+func (r *ignoreRules) loadDomain(domain []string) {
+	key := strings.Join(domain, "/")
+	if r.loaded[key] {
+		return
+	}
+	r.loaded[key] = true
+	// .irisignore is read after .gitignore so its rules (including negations) take precedence
+	names := []string{irisIgnoreFileName}
+	if r.useGit {
+		names = []string{gitIgnoreFileName, irisIgnoreFileName}
+	}
+	for _, name := range names {
+		data, err := fs.ReadFile(r.fsys, path.Join(path.Join(domain...), name))
+		if err != nil {
+			continue // Missing files yield no patterns
+		}
+		// Copy the domain, the patterns keep a reference to it
+		r.patterns = append(r.patterns, parseIgnorePatterns(bytes.NewReader(data), append([]string(nil), domain...))...)
+	}
+	r.matcher = gitignore.NewMatcher(r.patterns)
+}
+
+// Match reports whether path (at or below root) is ignored.
+func (r *ignoreRules) Match(path string, isDir bool) bool {
+	if r == nil || r.matcher == nil {
+		return false
+	}
+	components, ok := r.relComponents(path)
+	if !ok || len(components) == 0 {
+		return false
+	}
+	return r.matcher.Match(components, isDir)
+}
+
+// relComponents returns the components of path relative to base, or false if
+// path is not inside root.
+func (r *ignoreRules) relComponents(path string) ([]string, bool) {
+	rel, err := filepath.Rel(r.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, false
+	}
+	return append(append([]string(nil), r.rootPath...), splitPath(rel)...), true
+}
+
+// readIgnoreFile parses a gitignore-syntax file. Missing files yield no patterns.
+func readIgnoreFile(path string, domain []string) []gitignore.Pattern {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	return parseIgnorePatterns(f, domain)
+}
+
+// parseIgnorePatterns parses gitignore-syntax lines, scoping them to domain.
+func parseIgnorePatterns(r io.Reader, domain []string) []gitignore.Pattern {
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+	return patterns
+}
+
+// defaultGlobalIgnoreFile returns git's default global excludes file location.
+func defaultGlobalIgnoreFile() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "git", "ignore")
+}
+
+// findGitRoot walks up from dir looking for a worktree root, i.e. a directory
+// containing .git (a directory, or a file for linked worktrees and submodules).
+func findGitRoot(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// gitCommonDir returns the git directory holding info/exclude for the worktree at
+// root. That is root/.git itself, except in linked worktrees and submodule checkouts
+// where .git is a "gitdir: <path>" file; a linked worktree's gitdir in turn names
+// the repository's shared directory in its commondir file.
+func gitCommonDir(root string) string {
+	dotGit := filepath.Join(root, ".git")
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return dotGit // A directory (or missing)
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return dotGit
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(root, gitDir)
+	}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		return filepath.Clean(commonDir)
+	}
+	return gitDir
+}
+
+// splitPath splits a relative path into its components; "." yields none.
+func splitPath(rel string) []string {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if rel == "." || rel == "" {
+		return nil
+	}
+	return strings.Split(rel, "/")
+}
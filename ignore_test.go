@@ -0,0 +1,238 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":          {Data: []byte("# comment\n*.log\n!keep.log\nbuild/\n")},
+		".irisignore":         {Data: []byte("secret.txt\n")},
+		"sub/.gitignore":      {Data: []byte("*.tmp\n!debug.log\n/local.txt\n")},
+		"sub/deep/.gitignore": {Data: []byte("!*.tmp\n")},
+	}
+	src := inputSource{fsys: fsys, root: "repo"}
+
+	tests := []struct {
+		path   string
+		isDir  bool
+		useGit bool
+		want   bool
+	}{
+		{"repo/app.log", false, true, true},
+		{"repo/keep.log", false, true, false},      // Negated at the root
+		{"repo/sub/app.log", false, true, true},    // Root rules apply below
+		{"repo/sub/debug.log", false, true, false}, // Negated inside sub only
+		{"repo/debug.log", false, true, true},      // sub's negation doesn't reach up
+		{"repo/build", true, true, true},           // Directory-only rule
+		{"repo/build", false, true, false},         // ...which doesn't match files
+		{"repo/x.tmp", false, true, false},         // sub's rules are scoped to sub
+		{"repo/sub/x.tmp", false, true, true},
+		{"repo/sub/deep/x.tmp", false, true, false},     // Re-included by the deeper file
+		{"repo/sub/local.txt", false, true, true},       // Anchored to sub
+		{"repo/sub/deep/local.txt", false, true, false}, // ...not to its subdirectories
+		{"repo/secret.txt", false, true, true},
+		{"repo/secret.txt", false, false, true}, // .irisignore applies without git rules
+		{"repo/app.log", false, false, false},
+		{"repo", true, true, false},           // The root itself is never ignored
+		{"other/app.log", false, true, false}, // Outside root
+	}
+	for _, tt := range tests {
+		rules := src.ignoreRules(tt.useGit)
+		rules.loadDir("repo/sub")
+		rules.loadDir("repo/sub/deep")
+		if got := rules.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, isDir=%v, useGit=%v) = %v, want %v", tt.path, tt.isDir, tt.useGit, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreRulesNil(t *testing.T) {
+	var rules *ignoreRules
+	rules.loadDir("x")
+	if rules.Match("x/a.log", false) {
+		t.Error("nil ignoreRules matched a path")
+	}
+}
+
+func TestNewIgnoreRulesAnchorsAtGitRoot(t *testing.T) {
+	// Keep the user's global excludes out of the test
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	repo := t.TempDir()
+	writeTestFile(t, filepath.Join(repo, ".git", "info", "exclude"), "*.bak\n")
+	writeTestFile(t, filepath.Join(repo, ".gitignore"), "/pkg/gen/\n*.log\n")
+	writeTestFile(t, filepath.Join(repo, "pkg", ".gitignore"), "!keep.log\n")
+
+	// Walking a subdirectory still applies the ignore files above it, scoped to
+	// the repository root
+	root := filepath.Join(repo, "pkg")
+	rules := newIgnoreRules(root, true)
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{filepath.Join(root, "gen"), true, true},
+		{filepath.Join(root, "sub", "gen"), true, false},
+		{filepath.Join(root, "a.log"), false, true},
+		{filepath.Join(root, "keep.log"), false, false},
+		{filepath.Join(root, "a.bak"), false, true},
+		{filepath.Join(root, "a.go"), false, false},
+	}
+	for _, tt := range tests {
+		if got := rules.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, isDir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	if newIgnoreRules(root, false).Match(filepath.Join(root, "a.log"), false) {
+		t.Error("git ignore rules applied with useGit=false")
+	}
+}
+
+func TestGitCommonDir(t *testing.T) {
+	root := t.TempDir()
+	if got, want := gitCommonDir(root), filepath.Join(root, ".git"); got != want {
+		t.Errorf("gitCommonDir() without .git = %s, want %s", got, want)
+	}
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gitCommonDir(root), filepath.Join(root, ".git"); got != want {
+		t.Errorf("gitCommonDir() of a repository = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		dotGit    string // Content of the .git file, relative to the checkout
+		commonDir string // Content of the gitdir's commondir file, "" for none; "<base>" expands to the test directory
+		want      string // Relative to the checkout
+	}{
+		{"submodule checkout", "gitdir: ../.git/modules/lib\n", "", "../.git/modules/lib"},
+		{"linked worktree", "gitdir: ../main/.git/worktrees/wt\n", "../..\n", "../main/.git"},
+		{"absolute commondir", "gitdir: ../main/.git/worktrees/wt", "<base>/main/.git", "../main/.git"},
+		{"not a gitdir file", "garbage\n", "", ".git"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			checkout := filepath.Join(base, "checkout")
+			writeTestFile(t, filepath.Join(checkout, ".git"), tt.dotGit)
+			if tt.commonDir != "" {
+				gitDir := filepath.Join(checkout, filepath.FromSlash(strings.TrimSpace(strings.TrimPrefix(tt.dotGit, "gitdir:"))))
+				commonDir := strings.ReplaceAll(tt.commonDir, "<base>", filepath.ToSlash(base))
+				writeTestFile(t, filepath.Join(gitDir, "commondir"), filepath.FromSlash(commonDir))
+			}
+			if got, want := gitCommonDir(checkout), filepath.Join(checkout, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("gitCommonDir() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// linkWorktree adds a linked worktree of repo at HEAD, laid out as `git worktree
+// add` does, and returns its directory.
+func linkWorktree(t *testing.T, repo *testRepo) string {
+	t.Helper()
+	head, err := repo.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	gitDir := repo.path(".git/worktrees/wt")
+	writeTestFile(t, filepath.Join(gitDir, "HEAD"), head.Hash().String()+"\n")
+	writeTestFile(t, filepath.Join(gitDir, "commondir"), "../..\n")
+	writeTestFile(t, filepath.Join(gitDir, "gitdir"), filepath.Join(dir, ".git")+"\n")
+	writeTestFile(t, filepath.Join(dir, ".git"), "gitdir: "+gitDir+"\n")
+	index, err := os.ReadFile(repo.path(".git/index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(gitDir, "index"), string(index))
+
+	// Check out HEAD's files
+	commit, err := repo.repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := commit.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = files.ForEach(func(f *object.File) error {
+		content, err := f.Contents()
+		if err == nil {
+			writeTestFile(t, filepath.Join(dir, filepath.FromSlash(f.Name)), content)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLinkedWorktree(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	repo := newTestRepo(t)
+	repo.write(t, "main.go", "package main\n")
+	repo.write(t, "util.go", "package main\n\nfunc util() {}\n")
+	repo.commitAs(t, "Alice", "Initial commit")
+	writeTestFile(t, repo.path(".git/info/exclude"), "*.bak\n")
+	worktree := linkWorktree(t, repo)
+	writeTestFile(t, filepath.Join(worktree, "notes.bak"), "scratch\n")
+	writeTestFile(t, filepath.Join(worktree, "main.go"), "package main\n\nfunc main() {}\n")
+	resetSkipReport(t)
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	setFlag(t, &sinceRef, "")
+	setFlag(t, &diffRef, "")
+
+	// The shared info/exclude applies, and .git (a file here) is never listed
+	setFlag(t, &changedOnly, false)
+	setFlag(t, &showHidden, true)
+	files, err := processLocalPath(worktree, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file.Path))
+	}
+	if want := []string{"main.go", "util.go"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("walked %v, want %v", names, want)
+	}
+
+	// Change selection compares against the worktree's own HEAD and index
+	setFlag(t, &changedOnly, true)
+	setFlag(t, &showHidden, false)
+	files, err = processLocalPath(worktree, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Base(files[0].Path) != "main.go" || files[0].ChangeStatus != "M" {
+		t.Errorf("changed files = %+v, want main.go modified", files)
+	}
+
+	// History is read through the shared object store
+	setFlag(t, &gitLogCount, 5)
+	setFlag(t, &gitLogScope, "file")
+	setFlag(t, &blameSummary, true)
+	files = []FileInfo{{Path: filepath.Join(worktree, "util.go")}}
+	addGitContext(files, nil)
+	if len(files[0].History) != 1 || files[0].History[0].Author != "Alice" || files[0].Blame == nil {
+		t.Errorf("util.go history = %+v, blame = %+v", files[0].History, files[0].Blame)
+	}
+}
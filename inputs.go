@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// stdinInput is the input argument that reads content from standard input.
+const stdinInput = "-"
+
+// defaultStdinName is the display path of stdin content without --stdin-name.
+const defaultStdinName = "stdin"
+
+// inputArg is one input of a run: a path, URL or '-' from the command line or
+// the interactive finder, or the --files-from list.
+type inputArg struct {
+	path     string
+	fileList bool // Lists the files to process (--files-from) rather than being one
+}
+
+// processStdin reads standard input as a single virtual file. It is named by
+// --stdin-name, which also drives language detection, and is kept regardless of
+// filters since it was passed explicitly.
+func processStdin() ([]FileInfo, error) {
+	fmt.Fprintln(logOut, "Reading content from stdin...")
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("error reading stdin: %w", err)
+	}
+	name := stdinName
+	if name == "" {
+		name = defaultStdinName
+	}
+	file := FileInfo{
+		Path:  filepath.Clean(name),
+		Size:  int64(len(content)),
+		Mode:  0o644,
+		Stdin: true,
+	}
+	file.Source, file.SourcePath = contentSource(stdinInput, content)
+	return []FileInfo{file}, nil
+}
+
+// processFileList returns the files named in the --files-from list (or stdin for
+// "-"), one path per line or NUL-separated with --null. The paths are taken as
+// given, as from `git ls-files` or `fd`: nothing is discovered, and hidden and
+// ignore rules don't apply, but --include/--exclude, languages and --max-size
+// still do. Directories and unreadable paths are reported and skipped.
+func processFileList(list string, langData *LoadedLanguageData) ([]FileInfo, error) {
+	var data []byte
+	var err error
+	if list == stdinInput {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(list)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading file list %s: %w", list, err)
+	}
+
+	separator := []byte("\n")
+	if nullSeparated {
+		separator = []byte{0}
+	}
+	filter := newEntryFilter(langData)
+	seen := make(map[string]bool)
+	var files []FileInfo
+	for _, entry := range bytes.Split(data, separator) {
+		entry = bytes.TrimSuffix(entry, []byte("\r"))
+		if len(entry) == 0 {
+			continue
+		}
+		path := filepath.Clean(string(entry))
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not access listed path %s: %v\n", path, err)
+			continue
+		}
+		if info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Warning: skipping listed directory %s (--files-from takes files only)\n", path)
+			continue
+		}
+		if reason, skip := filter.skipFile(path, filepath.ToSlash(path)); skip {
+			if reason != "" {
+				recordSkip(path, reason)
+			}
+			continue
+		}
+		if reason, skip := filter.skipSize(info.Size()); skip {
+			recordSkip(path, reason)
+			continue
+		}
+		file := FileInfo{
+			Path:    path,
+			Size:    info.Size(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		}
+		file.Source, file.SourcePath = fileSource(path)
+		files = append(files, file)
+	}
+	fmt.Fprintf(logOut, "Processing %d listed file(s) from %s\n", len(files), list)
+	return files, nil
+}
+
+// localRelative reports whether every file lies below the working directory,
+// so a tree rooted at "." can be drawn for them.
+func localRelative(files []FileInfo) bool {
+	for _, file := range files {
+		if !filepath.IsLocal(file.Path) {
+			return false
+		}
+	}
+	return true
+}
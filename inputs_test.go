@@ -0,0 +1,184 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useStdin makes os.Stdin read content for the rest of the test.
+func useStdin(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	writeTestFile(t, path, content)
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stdin
+	os.Stdin = file
+	t.Cleanup(func() {
+		os.Stdin = old
+		file.Close()
+	})
+}
+
+// captureStderr redirects os.Stderr for the rest of the test and returns a
+// function that reads what was written so far.
+func captureStderr(t *testing.T) func() string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stderr")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stderr
+	os.Stderr = file
+	t.Cleanup(func() {
+		os.Stderr = old
+		file.Close()
+	})
+	return func() string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+}
+
+func TestProcessStdin(t *testing.T) {
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	tests := []struct {
+		name, want string
+	}{
+		{"", defaultStdinName},
+		{"main.go", "main.go"},
+		{"./cmd//tool/main.go", filepath.Join("cmd", "tool", "main.go")},
+	}
+	for _, tt := range tests {
+		const content = "package main\n\nfunc main() {}\n"
+		useStdin(t, content)
+		setFlag(t, &stdinName, tt.name)
+
+		files, err := processStdin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 {
+			t.Fatalf("processStdin() returned %d files", len(files))
+		}
+		file := files[0]
+		if file.Path != tt.want || !file.Stdin || file.Size != int64(len(content)) {
+			t.Errorf("--stdin-name %q: file = %+v, want path %s", tt.name, file, tt.want)
+		}
+
+		processed, store, err := processFiles(files, byteTokenizer{}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.content(processed[0])
+		store.Close()
+		if err != nil || string(got) != content {
+			t.Errorf("--stdin-name %q: content = %q, %v; want %q", tt.name, got, err, content)
+		}
+	}
+}
+
+// listedPaths returns the slash-separated paths of files.
+func listedPaths(files []FileInfo) []string {
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, filepath.ToSlash(file.Path))
+	}
+	return paths
+}
+
+func TestProcessFileList(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeTestFile(t, "a.go", "package a\n")
+	writeTestFile(t, "docs/b.md", "# B\n")
+	writeTestFile(t, "big.txt", strings.Repeat("x", 100))
+	writeTestFile(t, ".hidden", "listed explicitly\n")
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	setFlag(t, &includePatterns, "")
+	setFlag(t, &excludePatterns, "")
+	setFlag(t, &maxSizeBytes, int64(0))
+	setFlag(t, &truncateLines, 0)
+	setFlag(t, &nullSeparated, false)
+
+	// Blank lines, CRLF endings and repeats of the same path are dropped;
+	// missing paths and directories are reported and skipped
+	list := "a.go\n\n./a.go\r\ndocs/b.md\r\nmissing.go\ndocs\n.hidden\ndocs/../a.go\n\n"
+	writeTestFile(t, "list.txt", list)
+	stderr := captureStderr(t)
+	files, err := processFileList("list.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := listedPaths(files), []string{"a.go", "docs/b.md", ".hidden"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+	warnings := stderr()
+	for _, want := range []string{"could not access listed path missing.go", "skipping listed directory docs"} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("warnings %q do not mention %q", warnings, want)
+		}
+	}
+	if files[0].Size != int64(len("package a\n")) || files[0].Source == nil {
+		t.Errorf("a.go = %+v, want its size and a source to read it from", files[0])
+	}
+
+	// The same list from stdin, NUL-separated
+	setFlag(t, &nullSeparated, true)
+	useStdin(t, strings.ReplaceAll(list, "\n", "\x00"))
+	files, err = processFileList(stdinInput, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := listedPaths(files), []string{"a.go", "docs/b.md", ".hidden"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listed %v from stdin, want %v", got, want)
+	}
+	setFlag(t, &nullSeparated, false)
+
+	// Patterns and --max-size still apply
+	writeTestFile(t, "list.txt", "a.go\ndocs/b.md\nbig.txt\n")
+	setFlag(t, &excludePatterns, "docs/**")
+	setFlag(t, &maxSizeBytes, int64(50))
+	files, err = processFileList("list.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := listedPaths(files), []string{"a.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listed %v with filters, want %v", got, want)
+	}
+
+	if _, err := processFileList("no-such-list.txt", nil); err == nil {
+		t.Error("processFileList accepted a missing list file")
+	}
+}
+
+func TestLocalRelative(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  bool
+	}{
+		{nil, true},
+		{[]string{"a.go", "docs/b.md"}, true},
+		{[]string{"a.go", "../other/b.md"}, false},
+		{[]string{filepath.Join(string(filepath.Separator), "abs", "c.go")}, false},
+	}
+	for _, tt := range tests {
+		var files []FileInfo
+		for _, path := range tt.paths {
+			files = append(files, FileInfo{Path: filepath.FromSlash(path)})
+		}
+		if got := localRelative(files); got != tt.want {
+			t.Errorf("localRelative(%v) = %v, want %v", tt.paths, got, tt.want)
+		}
+	}
+}
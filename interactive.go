@@ -17,6 +17,8 @@ func runInteractiveFinder() ([]string, error) {
 	candidates := []string{}
 	root := "." // Start from current directory
 
+	ignoreMatcher := newIgnoreRules(root, !noIgnore)
+
 	// We need a simplified walk just to get paths for the finder
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -39,8 +41,16 @@ func runInteractiveFinder() ([]string, error) {
 			return nil
 		}
 
-		// TODO: Optionally add .gitignore filtering here for a cleaner list?
-		// Requires loading gitignore from "."
+		// Same ignore rules as walkDirectory for a cleaner list
+		if ignoreMatcher.Match(path, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			ignoreMatcher.loadDir(path)
+		}
 
 		candidates = append(candidates, path)
 		return nil
@@ -80,7 +90,7 @@ func runInteractiveFinder() ([]string, error) {
 
 	if err != nil {
 		if err == fuzzyfinder.ErrAbort { // User pressed Esc or Ctrl+C
-			fmt.Println("Interactive selection aborted.")
+			fmt.Fprintln(logOut, "Interactive selection aborted.")
 			return nil, nil // Return nil slice and nil error to indicate graceful exit
 		}
 		return nil, fmt.Errorf("fuzzy finder error: %w", err)
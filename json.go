@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// validResultFormats lists the values accepted by --format.
+var validResultFormats = []string{"text", "json", "jsonl"}
+
+// isValidResultFormat reports whether format is one of validResultFormats.
+func isValidResultFormat(format string) bool {
+	return containsString(validResultFormats, format)
+}
+
+// jsonFile is the machine-readable representation of a single FileInfo.
+type jsonFile struct {
+	Type           string        `json:"type,omitempty"` // Only set for jsonl records
+	Path           string        `json:"path"`
+	Size           int64         `json:"size"`
+	Mode           string        `json:"mode"`
+	Language       string        `json:"language,omitempty"`
+	Tokens         *int          `json:"tokens,omitempty"`          // nil when token counting is disabled
+	OriginalTokens int           `json:"original_tokens,omitempty"` // Tokens before truncation/compression
+	Error          string        `json:"error,omitempty"`
+	Binary         bool          `json:"binary,omitempty"`
+	Change         string        `json:"change,omitempty"`    // A/M/D/R with --changed/--since
+	Submodule      string        `json:"submodule,omitempty"` // Pinned commit of a submodule directory
+	Skipped        string        `json:"skipped,omitempty"`   // Reason the content was withheld
+	History        []CommitInfo  `json:"history,omitempty"`   // Recent commits with --git-log
+	Blame          *BlameSummary `json:"blame,omitempty"`     // Top authors with --blame-summary
+	Content        *string       `json:"content,omitempty"`   // nil unless the output format shows files
+}
+
+// jsonReport is the single document emitted by --format json.
+type jsonReport struct {
+	Version       string     `json:"version"`
+	Inputs        []string   `json:"inputs"`
+	OutputFormat  string     `json:"output_format"`
+	TokensEnabled bool       `json:"tokens_enabled"`
+	Summary       Summary    `json:"summary"`
+	Files         []jsonFile `json:"files"`
+}
+
+// jsonSummaryRecord is the trailing record emitted by --format jsonl.
+type jsonSummaryRecord struct {
+	Type          string   `json:"type"`
+	Version       string   `json:"version"`
+	Inputs        []string `json:"inputs"`
+	OutputFormat  string   `json:"output_format"`
+	TokensEnabled bool     `json:"tokens_enabled"`
+	Summary
+}
+
+// newJSONFile converts a FileInfo. Content is added by setContent for files whose
+// content is shown (see jsonShowsContent).
+func newJSONFile(file FileInfo, langData *LoadedLanguageData) jsonFile {
+	jf := jsonFile{
+		Path:     file.Path,
+		Size:     file.Size,
+		Mode:     file.Mode.String(),
+		Language: languageForFile(file, langData),
+	}
+	if !disableTokens {
+		tokens := file.TokenCount
+		jf.Tokens = &tokens
+		jf.OriginalTokens = file.OriginalTokenCount
+	}
+	if file.Error != nil {
+		jf.Error = file.Error.Error()
+	}
+	jf.Binary = file.Binary
+	jf.Change = file.ChangeStatus
+	jf.Submodule = file.Submodule
+	jf.Skipped = file.SkipReason
+	jf.History = file.History
+	jf.Blame = file.Blame
+	return jf
+}
+
+// jsonShowsContent reports whether a file's record includes its content: the
+// output format shows files and the file's content is emitted.
+func jsonShowsContent(file FileInfo) bool {
+	return formatShowsFiles(outputFormat) && file.SkipReason == "" && !file.IsDir
+}
+
+// setContent adds the emitted content to the record, or the error reading it.
+func (jf *jsonFile) setContent(content []byte, readErr error) {
+	if readErr != nil {
+		if jf.Error == "" {
+			jf.Error = fmt.Sprintf("error reading file: %v", readErr)
+		}
+		return
+	}
+	text := string(content)
+	jf.Content = &text
+}
+
+// sortedFiles returns the non-directory entries of files, plus submodules, sorted by path.
+func sortedFiles(files []FileInfo) []FileInfo {
+	sort.Slice(files, func(i, j int) bool { // Sort by path for consistent output
+		return files[i].Path < files[j].Path
+	})
+	result := make([]FileInfo, 0, len(files))
+	for _, file := range files {
+		if !file.IsDir || file.Submodule != "" {
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
+// printJSON writes the --format json document describing the whole run. The
+// files are encoded and written one at a time rather than as one large value,
+// producing the same document as encoding a complete jsonReport.
+func printJSON(w io.Writer, files []FileInfo, inputs []string, summary Summary, langData *LoadedLanguageData, contents *contentStore) error {
+	report := jsonReport{
+		Version:       version,
+		Inputs:        inputs,
+		OutputFormat:  outputFormat,
+		TokensEnabled: !disableTokens,
+		Summary:       summary,
+		Files:         []jsonFile{},
+	}
+	var header bytes.Buffer
+	encoder := json.NewEncoder(&header)
+	encoder.SetEscapeHTML(false) // File contents are code, keep <, > and & readable
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		// Only possible with unsupported types, which jsonReport does not contain
+		return fmt.Errorf("encoding JSON output: %w", err)
+	}
+	files = sortedFiles(files)
+	if len(files) == 0 {
+		_, err := w.Write(header.Bytes())
+		return err
+	}
+
+	// Everything up to the empty "files" array, which is filled in below
+	out := bufio.NewWriter(w)
+	out.Write(bytes.TrimSuffix(header.Bytes(), []byte("[]\n}\n")))
+	out.WriteString("[\n")
+	var record bytes.Buffer
+	encoder = json.NewEncoder(&record)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("    ", "  ")
+	for i, file := range files {
+		jf := newJSONFile(file, langData)
+		if jsonShowsContent(file) {
+			jf.setContent(contents.content(file))
+		}
+		record.Reset()
+		if err := encoder.Encode(jf); err != nil {
+			return fmt.Errorf("encoding JSON output for %s: %w", file.Path, err)
+		}
+		if i > 0 {
+			out.WriteString(",\n")
+		}
+		out.WriteString("    ")
+		out.Write(bytes.TrimSuffix(record.Bytes(), []byte("\n"))) // The separator goes after the closing brace
+	}
+	out.WriteString("\n  ]\n}\n")
+	return out.Flush()
+}
+
+// printJSONL writes the --format jsonl stream: one "file" record per line,
+// followed by a final "summary" record.
+func printJSONL(w io.Writer, files []FileInfo, inputs []string, summary Summary, langData *LoadedLanguageData, contents *contentStore) error {
+	out := bufio.NewWriter(w)
+	encoder := json.NewEncoder(out) // Encode writes one value per line
+	encoder.SetEscapeHTML(false)
+
+	files = sortedFiles(files)
+	for _, file := range files {
+		record := newJSONFile(file, langData)
+		record.Type = "file"
+		if jsonShowsContent(file) {
+			record.setContent(contents.content(file))
+		}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("encoding JSONL record for %s: %w", file.Path, err)
+		}
+	}
+
+	record := jsonSummaryRecord{
+		Type:          "summary",
+		Version:       version,
+		Inputs:        inputs,
+		OutputFormat:  outputFormat,
+		TokensEnabled: !disableTokens,
+		Summary:       summary,
+	}
+	if err := encoder.Encode(record); err != nil {
+		return fmt.Errorf("encoding JSONL summary: %w", err)
+	}
+	return out.Flush()
+}
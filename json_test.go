@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// jsonTestFiles returns a directory, two files with content (one transformed)
+// and a skipped binary file, out of order.
+func jsonTestFiles(t *testing.T) ([]FileInfo, *contentStore) {
+	t.Helper()
+	return storedTestFiles(t, []FileInfo{
+		{Path: "src", IsDir: true, Mode: fs.ModeDir | 0o755},
+		{Path: "src/main.go", Size: 27, Mode: 0o644, Language: "Go", TokenCount: 27},
+		{Path: "logo.png", Size: 100, Mode: 0o644, Binary: true, SkipReason: binarySkipReason},
+		{Path: "a.txt", Size: 12, Mode: 0o644, Language: "Text", TokenCount: 6, OriginalTokenCount: 12, ChangeStatus: "M"},
+	}, []string{"", "package main\n\nfunc a() {} // <&>\n", "", "hello\n"})
+}
+
+func setJSONTestFlags(t *testing.T) {
+	t.Helper()
+	setFlag(t, &version, "v1.2.3")
+	setFlag(t, &outputFormat, "both")
+	setFlag(t, &disableTokens, false)
+}
+
+var jsonTestSummary = Summary{TotalFiles: 2, TotalSize: 39, TotalTokens: 33}
+
+func TestPrintJSON(t *testing.T) {
+	setJSONTestFlags(t)
+	files, store := jsonTestFiles(t)
+	var out strings.Builder
+	if err := printJSON(&out, files, []string{"."}, jsonTestSummary, nil, store); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "version": "v1.2.3",
+  "inputs": [
+    "."
+  ],
+  "output_format": "both",
+  "tokens_enabled": true,
+  "summary": {
+    "total_files": 2,
+    "total_size": 39,
+    "total_tokens": 33,
+    "failed_paths": 0
+  },
+  "files": [
+    {
+      "path": "a.txt",
+      "size": 12,
+      "mode": "-rw-r--r--",
+      "language": "Text",
+      "tokens": 6,
+      "original_tokens": 12,
+      "change": "M",
+      "content": "hello\n"
+    },
+    {
+      "path": "logo.png",
+      "size": 100,
+      "mode": "-rw-r--r--",
+      "tokens": 0,
+      "binary": true,
+      "skipped": "binary"
+    },
+    {
+      "path": "src/main.go",
+      "size": 27,
+      "mode": "-rw-r--r--",
+      "language": "Go",
+      "tokens": 27,
+      "content": "package main\n\nfunc a() {} // <&>\n"
+    }
+  ]
+}
+`
+	if out.String() != want {
+		t.Errorf("printJSON() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestPrintJSONWithoutFiles(t *testing.T) {
+	setJSONTestFlags(t)
+	setFlag(t, &outputFormat, "tree")
+	setFlag(t, &disableTokens, true)
+	var out strings.Builder
+	if err := printJSON(&out, nil, []string{"."}, Summary{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "version": "v1.2.3",
+  "inputs": [
+    "."
+  ],
+  "output_format": "tree",
+  "tokens_enabled": false,
+  "summary": {
+    "total_files": 0,
+    "total_size": 0,
+    "total_tokens": 0,
+    "failed_paths": 0
+  },
+  "files": []
+}
+`
+	if out.String() != want {
+		t.Errorf("printJSON() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestPrintJSONL(t *testing.T) {
+	setJSONTestFlags(t)
+	files, store := jsonTestFiles(t)
+	var out strings.Builder
+	if err := printJSONL(&out, files, []string{"."}, jsonTestSummary, nil, store); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"file","path":"a.txt","size":12,"mode":"-rw-r--r--","language":"Text","tokens":6,"original_tokens":12,"change":"M","content":"hello\n"}
+{"type":"file","path":"logo.png","size":100,"mode":"-rw-r--r--","tokens":0,"binary":true,"skipped":"binary"}
+{"type":"file","path":"src/main.go","size":27,"mode":"-rw-r--r--","language":"Go","tokens":27,"content":"package main\n\nfunc a() {} // <&>\n"}
+{"type":"summary","version":"v1.2.3","inputs":["."],"output_format":"both","tokens_enabled":true,"total_files":2,"total_size":39,"total_tokens":33,"failed_paths":0}
+`
+	if out.String() != want {
+		t.Errorf("printJSONL() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write([]byte) (int, error) { return 0, w.err }
+
+func TestPrintJSONReportsWriteErrors(t *testing.T) {
+	setJSONTestFlags(t)
+	// Content larger than the output buffer makes the records themselves fail to write
+	big := strings.Repeat("x", 10000)
+	writeErr := errors.New("disk full")
+	for name, write := range map[string]func(w failingWriter, files []FileInfo, store *contentStore) error{
+		"json": func(w failingWriter, files []FileInfo, store *contentStore) error {
+			return printJSON(w, files, []string{"."}, Summary{}, nil, store)
+		},
+		"jsonl": func(w failingWriter, files []FileInfo, store *contentStore) error {
+			return printJSONL(w, files, []string{"."}, Summary{}, nil, store)
+		},
+	} {
+		files, store := storedTestFiles(t, []FileInfo{{Path: "big.txt", Size: int64(len(big))}}, []string{big})
+		if err := write(failingWriter{writeErr}, files, store); !errors.Is(err, writeErr) {
+			t.Errorf("%s: error = %v, want %v", name, err, writeErr)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/alecthomas/chroma/v2/lexers"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,6 +17,7 @@ type LanguageInfo struct {
 	Extensions   []string `yaml:"extensions"`
 	Filenames    []string `yaml:"filenames"`
 	Interpreters []string `yaml:"interpreters"`
+	Generated    []string `yaml:"generated"` // File name patterns of machine-written files (--skip-generated)
 	// Add other fields like color, language_id later
 	// TODO: maybe if I feel like it
 }
@@ -28,6 +30,7 @@ type LoadedLanguageData struct {
 	Langs        LanguageMap
 	extensionMap map[string]string // Map extension (e.g., ".go") to language name ("Go")
 	filenameMap  map[string]string // Map filename (e.g., "Makefile") to language name ("Makefile")
+	generated    []string          // Generated file patterns from all languages
 }
 
 // loadLanguageData attempts to load and parse languages.yml from standard locations.
@@ -52,7 +55,7 @@ func loadLanguageData() (*LoadedLanguageData, error) {
 		return nil, fmt.Errorf("languages.yml not found in standard config locations")
 	}
 
-	fmt.Printf("Loading language definitions from: %s\n", langFilePath)
+	fmt.Fprintf(logOut, "Loading language definitions from: %s\n", langFilePath)
 	yamlFile, err := os.ReadFile(langFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("error reading language file %s: %w", langFilePath, err)
@@ -86,12 +89,21 @@ func loadLanguageData() (*LoadedLanguageData, error) {
 				data.filenameMap[fname] = langName
 			}
 		}
+		data.generated = append(data.generated, info.Generated...)
 	}
 
-	fmt.Printf("Loaded %d languages with %d extensions and %d specific filenames.\n", len(data.Langs), len(data.extensionMap), len(data.filenameMap))
+	fmt.Fprintf(logOut, "Loaded %d languages with %d extensions and %d specific filenames.\n", len(data.Langs), len(data.extensionMap), len(data.filenameMap))
 	return data, nil
 }
 
+// GeneratedPatterns returns the generated file patterns declared in languages.yml.
+func (ld *LoadedLanguageData) GeneratedPatterns() []string {
+	if ld == nil {
+		return nil
+	}
+	return ld.generated
+}
+
 // GetLanguageForFile determines the language for a given path based on loaded data.
 func (ld *LoadedLanguageData) GetLanguageForFile(filePath string) (string, bool) {
 	if ld == nil {
@@ -117,3 +129,39 @@ func (ld *LoadedLanguageData) GetLanguageForFile(filePath string) (string, bool)
 
 	return "", false // No match found
 }
+
+// languageForFile returns the display language for a processed file, or "" if unknown.
+// Web pages are converted to Markdown before output, so they always report Markdown.
+// Binary files have no language, even when emitted as hex or base64.
+func languageForFile(file FileInfo, langData *LoadedLanguageData) string {
+	if isWebURL(file.Path) {
+		return "Markdown"
+	}
+	if file.Binary {
+		return ""
+	}
+	if file.Diff != nil {
+		return "Diff" // --diff replaced the content with a unified diff
+	}
+	if file.Language != "" {
+		return file.Language // Detected while processing, possibly from the content
+	}
+	return detectLanguage(file.Path, nil, langData)
+}
+
+// detectLanguage resolves a language name using languages.yml first, then falls back to
+// chroma's lexer registry (by filename, then by analysing content if it is available).
+func detectLanguage(path string, content []byte, langData *LoadedLanguageData) string {
+	if lang, ok := langData.GetLanguageForFile(path); ok {
+		return lang
+	}
+	if lexer := lexers.Match(filepath.Base(path)); lexer != nil {
+		return lexer.Config().Name
+	}
+	if len(content) > 0 {
+		if lexer := lexers.Analyse(string(content)); lexer != nil {
+			return lexer.Config().Name
+		}
+	}
+	return ""
+}
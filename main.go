@@ -2,15 +2,13 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
-	"sort"
 	"strings"
-	"sync"
 
-	"github.com/atotto/clipboard"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	// Tokenizer interface defined in tokenizer.go
@@ -18,7 +16,10 @@ import (
 
 var (
 	// Input Sources
-	inputPaths []string
+	inputPaths    []string
+	stdinName     string // Display name (and language hint) of content read from "-"
+	filesFrom     string // File listing the paths to process, "-" for stdin
+	nullSeparated bool   // --files-from entries are NUL-separated
 
 	// Filtering
 	includePatterns string
@@ -30,6 +31,8 @@ var (
 
 	// Output
 	outputFormat    string
+	resultFormat    string // text, json or jsonl
+	templatePath    string // Optional text/template file replacing the built-in text layout
 	outputFile      string
 	printToStdout   bool // Glimpse uses '-p', but true is the default unless -f or -c is used. Let's clarify behavior later.
 	copyToClipboard bool // Glimpse uses '-c'
@@ -43,6 +46,40 @@ var (
 	tokenizerModel string
 	tokenizerFile  string
 
+	// Content Transformations
+	compressMode   bool
+	outlineMode    bool
+	truncateLines  int
+	truncateTokens int
+
+	// Token Budget
+	maxTokens         int
+	budgetStrategy    string
+	includeBinary     string
+	skipGenerated     bool
+	skipVendored      bool
+	verbose           bool
+	redactSecrets     bool
+	failOnSecrets     bool
+	changedOnly       bool
+	sinceRef          string
+	diffRef           string
+	diffFull          bool
+	gitRef            string
+	gitSubdir         string
+	noCache           bool
+	gitHosts          string
+	gitLogCount       int
+	gitLogScope       string
+	blameSummary      bool
+	gitContextFormats string
+	submoduleMode     string
+	priorityPatterns  string
+
+	// Archives
+	maxArchiveSize    int64
+	maxArchiveEntries int
+
 	// Web Specific
 	traverseLinks bool
 	linkDepth     int
@@ -53,11 +90,20 @@ var (
 	// Interactive Mode
 	interactiveMode bool
 
-	cfgFile string // Variable to hold potential config file path flag (optional)
+	cfgFile     string // Variable to hold potential config file path flag (optional)
+	debugConfig bool   // Print config sources and effective settings
 
 	langData *LoadedLanguageData // Global or passed around?
 )
 
+// logOut receives progress and status messages. It defaults to stdout, but is switched
+// to stderr for machine-readable formats so their output can be piped into other tools.
+var logOut io.Writer = os.Stdout
+
+// exitCode is the process exit status, set by failures that happen after cleanup
+// has been deferred (so they return through it instead of calling os.Exit).
+var exitCode int
+
 // version holds the application version string.
 // It's set dynamically in init() using build info, but can be overridden by ldflags.
 var version string
@@ -70,29 +116,95 @@ and web URLs to generate structure views, display content, and count tokens.`,
 	Version: version,             // Set dynamically by initVersion()
 	Args:    cobra.ArbitraryArgs, // Allow paths to be passed as arguments
 	Run: func(cmd *cobra.Command, args []string) {
-		// initConfig and language loading are called via cobra.OnInitialize
+		// initConfig is called via cobra.OnInitialize; languages are only needed
+		// when processing, not by subcommands like `iris cache`
+		initLanguages()
+
+		if !isValidOutputFormat(outputFormat) {
+			fmt.Fprintf(os.Stderr, "Invalid output format '%s'. Use one of: %s\n", outputFormat, strings.Join(validOutputFormats, ", "))
+			os.Exit(1)
+		}
+		if !containsString(validBudgetStrategies, budgetStrategy) {
+			fmt.Fprintf(os.Stderr, "Invalid budget strategy '%s'. Use one of: %s\n", budgetStrategy, strings.Join(validBudgetStrategies, ", "))
+			os.Exit(1)
+		}
+		if !isValidBinaryMode(includeBinary) {
+			fmt.Fprintf(os.Stderr, "Invalid --include-binary mode '%s'. Use one of: %s\n", includeBinary, strings.Join(validBinaryModes, ", "))
+			os.Exit(1)
+		}
+		if diffRef != "" && sinceRef != "" && diffRef != sinceRef {
+			fmt.Fprintln(os.Stderr, "--diff and --since select changes against different refs; use one of them.")
+			os.Exit(1)
+		}
+		if !containsString(validSubmoduleModes, submoduleMode) {
+			fmt.Fprintf(os.Stderr, "Invalid --submodules mode '%s'. Use one of: %s\n", submoduleMode, strings.Join(validSubmoduleModes, ", "))
+			os.Exit(1)
+		}
+		if !containsString(validGitLogScopes, gitLogScope) {
+			fmt.Fprintf(os.Stderr, "Invalid --git-log-scope '%s'. Use one of: %s\n", gitLogScope, strings.Join(validGitLogScopes, ", "))
+			os.Exit(1)
+		}
+		for _, format := range parsePatterns(gitContextFormats) {
+			if !containsString(validGitContextFormats, format) {
+				fmt.Fprintf(os.Stderr, "Invalid --git-context-formats entry '%s'. Use any of: %s\n", format, strings.Join(validGitContextFormats, ", "))
+				os.Exit(1)
+			}
+		}
+		if !isValidResultFormat(resultFormat) {
+			fmt.Fprintf(os.Stderr, "Invalid format '%s'. Use one of: %s\n", resultFormat, strings.Join(validResultFormats, ", "))
+			os.Exit(1)
+		}
+
+		outputTemplate, err := loadOutputTemplate(templatePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading template: %v\n", err)
+			os.Exit(1)
+		}
+		if secretScanEnabled() {
+			secretRules, err = loadSecretRules()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading redaction rules: %v\n", err)
+				os.Exit(1)
+			}
+		}
 
 		// Determine input paths: interactive or command-line args
-		var finalInputPaths []string
-		var err error
+		var inputs []inputArg
 		if interactiveMode {
-			finalInputPaths, err = runInteractiveFinder()
+			var selected []string
+			selected, err = runInteractiveFinder()
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Interactive mode error: %v\n", err)
 				os.Exit(1)
 			}
-			if finalInputPaths == nil {
+			if selected == nil {
 				// User aborted interactive selection
 				os.Exit(0)
 			}
-			fmt.Printf("Processing interactively selected paths: %v\n", finalInputPaths)
+			fmt.Fprintf(logOut, "Processing interactively selected paths: %v\n", selected)
+			for _, path := range selected {
+				inputs = append(inputs, inputArg{path: path})
+			}
 		} else {
-			// Use command-line arguments
-			finalInputPaths = args
-			if len(finalInputPaths) == 0 {
-				finalInputPaths = []string{"."} // Default to current directory if no paths provided
+			// Use command-line arguments, then the --files-from list as one more input
+			for _, arg := range args {
+				inputs = append(inputs, inputArg{path: arg})
+			}
+			if filesFrom != "" {
+				if filesFrom == stdinInput && containsString(args, stdinInput) {
+					fmt.Fprintln(os.Stderr, "'-' and --files-from - both read stdin; use one of them.")
+					os.Exit(1)
+				}
+				inputs = append(inputs, inputArg{path: filesFrom, fileList: true})
+			}
+			if len(inputs) == 0 {
+				inputs = []inputArg{{path: "."}} // Default to current directory if no paths provided
 			}
 		}
+		finalInputPaths := make([]string, len(inputs))
+		for i, input := range inputs {
+			finalInputPaths[i] = input.path
+		}
 
 		// --- Initialize Tokenizer (if needed) ---
 		var tokenizer Tokenizer // Use the interface type
@@ -109,50 +221,98 @@ and web URLs to generate structure views, display content, and count tokens.`,
 		}
 
 		// --- Main Logic ---
-		fmt.Println("Iris running...")
+		fmt.Fprintln(logOut, "Iris running...")
 
 		var allFilesMaster []FileInfo // Collect files from all inputs first
 		var failedPaths int
 		var tempDirsToClean []string // Keep track of temp dirs for cleanup
+		var gitSources []GitSource   // Cloned repositories and the commits they were read at
 
 		// Ensure temporary directories are cleaned up on exit (even if errors occur)
-		defer func() {
+		cleanupTempDirs := func() {
 			for _, dir := range tempDirsToClean {
-				fmt.Printf("Cleaning up temporary directory: %s\n", dir)
+				fmt.Fprintf(logOut, "Cleaning up temporary directory: %s\n", dir)
 				_ = os.RemoveAll(dir)
 			}
-		}()
+			tempDirsToClean = nil
+		}
+		defer cleanupTempDirs()
 
-		for _, input := range finalInputPaths {
+		for _, arg := range inputs {
 			var filesToAppend []FileInfo
 			var err error
+			input := arg.path
 			currentInput := input
 
-			// Check Web URL FIRST
-			if isWebURL(currentInput) {
-				// Process web URL (potentially with traversal)
-				if traverseLinks {
-					fmt.Printf("Starting web traversal from %s (max depth: %d)\n", currentInput, linkDepth)
-					visited := make(map[string]bool)
-					filesToAppend, err = processWebURLRecursive(currentInput, 0, linkDepth, visited)
-				} else {
-					var fileInfo FileInfo
-					fileInfo, err = processWebURL(currentInput)
-					if err == nil {
-						filesToAppend = []FileInfo{fileInfo}
-					}
+			// The --files-from list and stdin are checked FIRST, their files are not discovered
+			if arg.fileList {
+				filesToAppend, err = processFileList(currentInput, langData)
+				if localRelative(filesToAppend) {
+					inputTreeRoots[input] = "."
+				}
+			} else if currentInput == stdinInput {
+				filesToAppend, err = processStdin()
+				if localRelative(filesToAppend) {
+					inputTreeRoots[input] = "."
 				}
+			} else if isArchiveInput(currentInput) {
+				// THEN archives, since they can be behind web (and forge) URLs too
+				filesToAppend, err = processArchive(currentInput, langData)
+				inputTreeRoots[input] = "."
 			} else if isGitURL(currentInput) {
-				// THEN check for Git URL
-				tempDir, cloneErr := cloneGitRepo(currentInput)
+				// THEN Git URLs, since forge links are web URLs too (optionally pinned with #ref or --ref)
+				repoURL, subdir, ref, treePath := parseGitInput(currentInput)
+				var cloneErr error
+				if treePath != "" {
+					// A tree link's ref and directory come after explicit ones but before the flags
+					var treeRef, treeSubdir string
+					treeRef, treeSubdir, cloneErr = splitTreePath(repoURL, treePath)
+					if ref == "" {
+						ref = treeRef
+					}
+					if subdir == "" {
+						subdir = treeSubdir
+					}
+				}
+				if ref == "" {
+					ref = gitRef
+				}
+				if subdir == "" {
+					subdir = cleanGitSubdir(gitSubdir)
+				}
+				var tempDir, commit string
+				if cloneErr == nil {
+					tempDir, commit, cloneErr = cloneGitRepo(repoURL, ref, subdir)
+				}
 				if cloneErr != nil {
 					fmt.Fprintf(os.Stderr, "Error cloning git repo %s: %v\n", currentInput, cloneErr)
 					err = cloneErr // Assign the error to be handled below
 				} else {
 					tempDirsToClean = append(tempDirsToClean, tempDir)
-					currentInput = tempDir // Process the cloned directory path
+					gitSources = append(gitSources, GitSource{URL: repoURL, Ref: ref, Subdir: subdir, Commit: commit})
+					gitCloneURLs[tempDir] = repoURL
+					currentInput = filepath.Join(tempDir, filepath.FromSlash(subdir)) // Process the cloned directory path
 					// Process the cloned directory as a local path
 					filesToAppend, err = processLocalPath(currentInput, langData)
+					// Show paths relative to the repository root, not the temp dir
+					relocateClonedFiles(filesToAppend, tempDir)
+					inputTreeRoots[input] = "."
+					if subdir != "" {
+						inputTreeRoots[input] = filepath.FromSlash(subdir)
+					}
+				}
+			} else if isWebURL(currentInput) {
+				// THEN process web URL (potentially with traversal)
+				if traverseLinks {
+					fmt.Fprintf(logOut, "Starting web traversal from %s (max depth: %d)\n", currentInput, linkDepth)
+					visited := make(map[string]bool)
+					filesToAppend, err = processWebURLRecursive(currentInput, 0, linkDepth, visited)
+				} else {
+					var fileInfo FileInfo
+					fileInfo, err = processWebURL(currentInput)
+					if err == nil {
+						filesToAppend = []FileInfo{fileInfo}
+					}
 				}
 			} else {
 				// FINALLY, assume local path
@@ -169,140 +329,120 @@ and web URLs to generate structure views, display content, and count tokens.`,
 			allFilesMaster = append(allFilesMaster, filesToAppend...)
 		}
 
-		// --- Parallel Token Counting (if enabled) ---
-		var processedFiles []FileInfo
-		if !disableTokens && tokenizer != nil { // Check if tokenizer was successfully initialized
-			numWorkers := numThreads
-			if numWorkers <= 0 {
-				numWorkers = runtime.NumCPU()
-			}
-			fmt.Printf("Using %d worker(s) for token counting.\n", numWorkers)
-
-			jobs := make(chan FileInfo, len(allFilesMaster))
-			results := make(chan FileInfo, len(allFilesMaster))
-			var wg sync.WaitGroup
+		// --- Parallel Content Processing, Binary Detection and Token Counting ---
+		// Every file is read and transformed once, here, so binaries are detected and tokens
+		// counted before output. The emitted content is kept in a spill file until it is written.
+		numWorkers := numThreads
+		if numWorkers <= 0 {
+			numWorkers = runtime.NumCPU()
+		}
+		fmt.Fprintf(logOut, "Using %d worker(s) for content processing.\n", numWorkers)
+		processedFiles, contents, err := processFiles(allFilesMaster, tokenizer, numWorkers)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing files: %v\n", err)
+			exitCode = 1
+			return
+		}
+		defer contents.Close()
 
-			// Start workers
-			for w := 0; w < numWorkers; w++ {
-				wg.Add(1)
-				// Pass the Tokenizer interface to the worker
-				go tokenWorker(tokenizer, jobs, results, &wg)
+		var binaryFiles int
+		for _, file := range processedFiles {
+			if file.SkipReason == binarySkipReason {
+				binaryFiles++
 			}
+		}
+		if binaryFiles > 0 {
+			fmt.Fprintf(logOut, "Skipped %d binary file(s) (use --include-binary to include them).\n", binaryFiles)
+		}
+		// --- End Content Processing ---
 
-			// Send jobs (now includes FileInfo from web URLs)
-			filesToProcess := 0
-			for _, file := range allFilesMaster {
-				if file.IsDir { // Directories don't need token counting
-					results <- file
-				} else {
-					// Send files and web content (as FileInfo) to workers
-					jobs <- file
-					filesToProcess++
-				}
-			}
-			close(jobs)
+		// --- Git History and Blame (if requested) ---
+		var repoHistory []RepoHistory
+		if gitContextEnabled() {
+			fmt.Fprintln(logOut, "Collecting git history...")
+			repoHistory = addGitContext(processedFiles, tokenizer)
+		}
 
-			// Wait for workers to finish
-			wg.Wait()
-			close(results)
+		if truncateTokens > 0 && tokenizer == nil {
+			fmt.Fprintln(os.Stderr, "Warning: --truncate-tokens requires token counting; only --truncate-lines will apply.")
+		}
 
-			// Collect results
-			processedFiles = make([]FileInfo, 0, len(allFilesMaster))
-			for res := range results {
-				processedFiles = append(processedFiles, res)
+		// Totals are filled in once the token budget has settled which files are emitted
+		summary := Summary{
+			FailedPaths: failedPaths,
+			GitSources:  gitSources,
+			History:     repoHistory,
+		}
+		renderer := outputRenderer{template: outputTemplate, inputs: finalInputPaths, langData: langData, contents: contents}
+
+		// --- Token Budget (if requested) ---
+		var omittedFiles []string
+		var omittedTokens int
+		if maxTokens > 0 {
+			if disableTokens || tokenizer == nil {
+				fmt.Fprintln(os.Stderr, "Warning: --max-tokens requires token counting; ignoring the token budget.")
+			} else {
+				priorities := parsePatterns(priorityPatterns)
+				omittedFiles, omittedTokens = applyTokenBudget(processedFiles, maxTokens, budgetStrategy, priorities, summary, tokenizer, renderer)
+				if len(omittedFiles) > 0 {
+					fmt.Fprintf(logOut, "Omitted %d file(s) to fit the %d token budget.\n", len(omittedFiles), maxTokens)
+				}
+				for _, path := range omittedFiles {
+					recordSkip(path, budgetSkipReason)
+				}
 			}
+		}
 
-		} else {
-			// If token counting is disabled, just use the initially collected files
-			processedFiles = allFilesMaster
+		if verbose {
+			printSkipReport(os.Stderr)
 		}
-		// --- End Token Counting ---
 
 		// --- Aggregation and Summary (using processedFiles) ---
-		var totalFiles int
-		var totalSize, totalTokens int64
-		for _, file := range processedFiles {
-			if !file.IsDir {
-				totalFiles++
-				totalSize += file.Size
-				if !disableTokens {
-					totalTokens += int64(file.TokenCount)
+		summarizeFiles(&summary, processedFiles, tokenizer)
+		if failOnSecrets && len(summary.Secrets) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: %d secret(s) detected, not writing output:\n", countSecrets(summary.Secrets))
+			for _, file := range sortedFiles(processedFiles) {
+				if len(file.Secrets) > 0 && file.SkipReason == "" {
+					fmt.Fprintf(os.Stderr, "  - %s (%s)\n", file.Path, formatSecretCounts(file.Secrets))
 				}
 			}
+			cleanupTempDirs()
+			contents.Close()
+			os.Exit(1)
 		}
-
-		summary := Summary{
-			TotalFiles:  totalFiles,
-			TotalSize:   totalSize,
-			TotalTokens: int(totalTokens),
+		if maxTokens > 0 && !disableTokens {
+			summary.TokenBudget = maxTokens
+			summary.OmittedFiles = omittedFiles
+			summary.OmittedTokens = omittedTokens
 		}
 
 		// --- Output Generation (using processedFiles) ---
 		if pdfOutputFile != "" {
 			// Prioritize PDF output if the flag is set
-			err = generatePDF(processedFiles, summary, outputFormat, langData, pdfOutputFile)
+			err = generatePDF(processedFiles, summary, outputFormat, langData, contents, pdfOutputFile)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error generating PDF: %v\n", err)
 				// Optionally, print to stdout as fallback?
 			}
 		} else { // Handle non-PDF output (file, clipboard, stdout)
-			// Generate the output string only when not creating PDF
-			var outputBuilder strings.Builder
-			if outputFormat == "tree" || outputFormat == "both" {
-				if len(finalInputPaths) == 1 && isDir(finalInputPaths[0]) { // Check original single input path type
-					rootNode := buildTree(processedFiles, finalInputPaths[0])
-					outputBuilder.WriteString(printTree(rootNode))
-				} else if len(processedFiles) > 0 { // Check if any files were processed
-					// If multiple inputs or single file input, show the message
-					outputBuilder.WriteString("Tree view generated for single directory input only.\nFiles found:\n")
-					sort.Slice(processedFiles, func(i, j int) bool {
-						return processedFiles[i].Path < processedFiles[j].Path
-					})
-					for _, file := range processedFiles {
-						outputBuilder.WriteString(fmt.Sprintf("- %s\n", file.Path))
-					}
-				}
-				if outputFormat == "both" {
-					outputBuilder.WriteString("\n")
-				}
-			}
-			if outputFormat == "files" || outputFormat == "both" {
-				outputBuilder.WriteString(printFiles(processedFiles, !disableTokens))
+			// The output is streamed to its destination as it is rendered
+			sink, err := openOutputSink()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error opening output: %v\n", err) // Only --file can fail to open, and err names it
+				exitCode = 1
+				return
 			}
-			// Add summary to the output string
-			outputBuilder.WriteString("\n--- Summary ---\n")
-			outputBuilder.WriteString(fmt.Sprintf("Total files processed: %d\n", summary.TotalFiles))
-			outputBuilder.WriteString(fmt.Sprintf("Total size: %d bytes\n", summary.TotalSize))
-			if !disableTokens {
-				outputBuilder.WriteString(fmt.Sprintf("Total tokens: %d\n", summary.TotalTokens))
+			err = renderer.write(sink, processedFiles, summary)
+			if err == nil && resultFormat == "text" && outputFile == "" && !copyToClipboard {
+				sink.WriteString("\n") // Text output on stdout ends with a blank line; structured output already ends with a newline
 			}
-			if failedPaths > 0 {
-				outputBuilder.WriteString(fmt.Sprintf("Paths failed to process: %d\n", failedPaths))
+			if err == nil {
+				err = sink.Close()
 			}
-
-			// Declare and assign finalOutput here
-			finalOutput := outputBuilder.String()
-
-			// Now handle the destination for the generated string
-			if outputFile != "" {
-				// Save to text file
-				err = os.WriteFile(outputFile, []byte(finalOutput), 0644)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error writing to file %s: %v\n", outputFile, err)
-				}
-				fmt.Printf("Output saved to %s\n", outputFile)
-			} else if copyToClipboard {
-				// Copy to clipboard
-				err = clipboard.WriteAll(finalOutput)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error writing to clipboard: %v\n", err)
-					fmt.Println("\n--- Output (clipboard failed) ---")
-					fmt.Println(finalOutput)
-				} else {
-					fmt.Println("Output copied to clipboard.")
-				}
-			} else { // Default to stdout
-				fmt.Println(finalOutput)
+			if err != nil {
+				sink.Abort() // Don't leave a partial --file behind
+				fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+				exitCode = 1
 			}
 		} // End of non-PDF output handling
 
@@ -311,13 +451,23 @@ and web URLs to generate structure views, display content, and count tokens.`,
 }
 
 func init() {
-	// Initialize version first, then config, then languages
-	cobra.OnInitialize(initVersion, initConfig, initLanguages)
+	// Initialize version first, then config
+	cobra.OnInitialize(initVersion, initConfig, initLogging)
 
 	// --- Flag Definitions & Viper Binding ---
 	// Optional: Allow specifying config file via flag
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/iris/config.toml)")
 
+	rootCmd.Flags().BoolVar(&debugConfig, "debug-config", false, "Print config file precedence and the effective settings")
+
+	// Input Sources
+	rootCmd.Flags().StringVar(&stdinName, "stdin-name", "", "Name (and language hint) for content read from stdin with '-', e.g. main.go (default \"stdin\")")
+	viper.BindPFlag("stdin_name", rootCmd.Flags().Lookup("stdin-name"))
+	rootCmd.Flags().StringVar(&filesFrom, "files-from", "", "Process the files listed in this file, one per line ('-' for stdin), e.g. from git ls-files")
+	viper.BindPFlag("files_from", rootCmd.Flags().Lookup("files-from"))
+	rootCmd.Flags().BoolVar(&nullSeparated, "null", false, "Entries of --files-from are separated by NUL bytes (git ls-files -z, fd -0)")
+	viper.BindPFlag("null", rootCmd.Flags().Lookup("null"))
+
 	// Filtering
 	rootCmd.Flags().StringVarP(&includePatterns, "include", "i", "", `Additional patterns to include (comma-separated, e.g. *.rs,*.go)`)
 	viper.BindPFlag("include", rootCmd.Flags().Lookup("include"))
@@ -332,11 +482,37 @@ func init() {
 	viper.BindPFlag("hidden", rootCmd.Flags().Lookup("hidden"))
 	rootCmd.Flags().BoolVar(&noIgnore, "no-ignore", false, "Don't respect .gitignore files")
 	viper.BindPFlag("no_ignore", rootCmd.Flags().Lookup("no-ignore")) // Use snake_case for viper key
+	rootCmd.Flags().StringVar(&includeBinary, "include-binary", "", "Include binary files instead of skipping them: hex, base64, or placeholder")
+	viper.BindPFlag("include_binary", rootCmd.Flags().Lookup("include-binary"))
+	rootCmd.Flags().BoolVar(&changedOnly, "changed", false, "Only include files with uncommitted or staged changes against HEAD (git repositories)")
+	viper.BindPFlag("changed", rootCmd.Flags().Lookup("changed"))
+	rootCmd.Flags().StringVar(&sinceRef, "since", "", "Only include files that differ from this branch, tag or commit (git repositories)")
+	viper.BindPFlag("since", rootCmd.Flags().Lookup("since"))
+	rootCmd.Flags().StringVar(&diffRef, "diff", "", "Emit a unified diff against this branch, tag or commit for each changed file (git repositories)")
+	viper.BindPFlag("diff", rootCmd.Flags().Lookup("diff"))
+	rootCmd.Flags().BoolVar(&diffFull, "diff-full", false, "With --diff, also include the full new version of each file after its diff")
+	viper.BindPFlag("diff_full", rootCmd.Flags().Lookup("diff-full"))
+	rootCmd.Flags().BoolVar(&skipGenerated, "skip-generated", false, "Skip generated files (Code generated headers, protobuf output, lockfiles, minified JS/CSS)")
+	viper.BindPFlag("skip_generated", rootCmd.Flags().Lookup("skip-generated"))
+	rootCmd.Flags().BoolVar(&skipVendored, "skip-vendored", false, "Skip vendored directories (vendor/, third_party/)")
+	viper.BindPFlag("skip_vendored", rootCmd.Flags().Lookup("skip-vendored"))
+	rootCmd.Flags().BoolVar(&verbose, "verbose", false, "Print a report of skipped files and the reason for each to stderr")
+	viper.BindPFlag("verbose", rootCmd.Flags().Lookup("verbose"))
+
+	// Secrets
+	rootCmd.Flags().BoolVar(&redactSecrets, "redact", false, "Replace detected secrets (API keys, tokens, private keys) with [REDACTED:type]")
+	viper.BindPFlag("redact", rootCmd.Flags().Lookup("redact"))
+	rootCmd.Flags().BoolVar(&failOnSecrets, "fail-on-secrets", false, "Exit with status 1 before writing output if any secret is detected")
+	viper.BindPFlag("fail_on_secrets", rootCmd.Flags().Lookup("fail-on-secrets"))
 
 	// Output
-	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "both", "Output format: tree, files, or both")
+	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "both", "Output format: tree, files, both, xml, or markdown")
 	viper.BindPFlag("output", rootCmd.Flags().Lookup("output"))
 	viper.BindPFlag("default_output_format", rootCmd.Flags().Lookup("output"))
+	rootCmd.Flags().StringVar(&resultFormat, "format", "text", "Result format: text, json, or jsonl (json/jsonl include content when --output shows files)")
+	viper.BindPFlag("format", rootCmd.Flags().Lookup("format"))
+	rootCmd.Flags().StringVar(&templatePath, "template", "", "Go text/template file used to render text output")
+	viper.BindPFlag("template", rootCmd.Flags().Lookup("template"))
 	rootCmd.Flags().StringVarP(&outputFile, "file", "f", "", "Save output to specified file")
 	viper.BindPFlag("file", rootCmd.Flags().Lookup("file"))
 	rootCmd.Flags().BoolVarP(&printToStdout, "print", "p", false, "Print to stdout (default unless -f, -c, or --pdf used)")
@@ -360,6 +536,50 @@ func init() {
 	rootCmd.Flags().StringVar(&tokenizerFile, "tokenizer-file", "", "Path to local tokenizer file")
 	viper.BindPFlag("tokenizer_file", rootCmd.Flags().Lookup("tokenizer-file"))
 
+	// Content Transformations
+	rootCmd.Flags().BoolVar(&compressMode, "compress", false, "Strip comments, docstrings and blank-line runs before counting and output")
+	viper.BindPFlag("compress", rootCmd.Flags().Lookup("compress"))
+	rootCmd.Flags().BoolVar(&outlineMode, "outline", false, "Reduce Go files to package, imports, types and function signatures with doc comments")
+	viper.BindPFlag("outline", rootCmd.Flags().Lookup("outline"))
+	rootCmd.Flags().IntVar(&truncateLines, "truncate-lines", 0, "Keep only the first and last lines of files longer than this (0 for no limit)")
+	viper.BindPFlag("truncate_lines", rootCmd.Flags().Lookup("truncate-lines"))
+	rootCmd.Flags().IntVar(&truncateTokens, "truncate-tokens", 0, "Keep only the head and tail of files with more tokens than this (0 for no limit)")
+	viper.BindPFlag("truncate_tokens", rootCmd.Flags().Lookup("truncate-tokens"))
+
+	// Token Budget
+	rootCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Fit output under this many tokens by omitting files (0 for no limit)")
+	viper.BindPFlag("max_tokens", rootCmd.Flags().Lookup("max-tokens"))
+	rootCmd.Flags().StringVar(&budgetStrategy, "budget-strategy", "smallest", "Which files to keep under --max-tokens: smallest, priority, or recent")
+	viper.BindPFlag("budget_strategy", rootCmd.Flags().Lookup("budget-strategy"))
+	rootCmd.Flags().StringVar(&priorityPatterns, "priority", "", "Patterns kept first by --budget-strategy priority (comma-separated, in order)")
+	viper.BindPFlag("priority", rootCmd.Flags().Lookup("priority"))
+
+	// Git
+	rootCmd.Flags().StringVar(&gitRef, "ref", "", "Branch, tag or commit SHA to check out for Git URL inputs (or use repo.git#ref)")
+	viper.BindPFlag("ref", rootCmd.Flags().Lookup("ref"))
+	rootCmd.Flags().StringVar(&gitSubdir, "git-subdir", "", "Only check out and process this directory of Git URL inputs (or use repo.git//path)")
+	viper.BindPFlag("git_subdir", rootCmd.Flags().Lookup("git-subdir"))
+	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "Clone Git URL inputs directly instead of through the local repository cache, whose first use of a repository makes a full mirror clone")
+	viper.BindPFlag("no_cache", rootCmd.Flags().Lookup("no-cache"))
+	rootCmd.Flags().StringVar(&gitHosts, "git-hosts", "", "Extra forge hosts whose https repository links are cloned (comma-separated, e.g. git.example.com)")
+	viper.BindPFlag("git_hosts", rootCmd.Flags().Lookup("git-hosts"))
+	rootCmd.Flags().StringVar(&submoduleMode, "submodules", "skip", "Git submodules: skip (list with pinned commit), include (top-level submodules) or recurse (nested too)")
+	viper.BindPFlag("submodules", rootCmd.Flags().Lookup("submodules"))
+	rootCmd.Flags().IntVar(&gitLogCount, "git-log", 0, "Include the last N commit messages touching each file (git repositories, 0 to disable)")
+	viper.BindPFlag("git_log", rootCmd.Flags().Lookup("git-log"))
+	rootCmd.Flags().StringVar(&gitLogScope, "git-log-scope", "file", "Scope of --git-log: file (per file) or repo (once per repository, in the summary)")
+	viper.BindPFlag("git_log_scope", rootCmd.Flags().Lookup("git-log-scope"))
+	rootCmd.Flags().BoolVar(&blameSummary, "blame-summary", false, "Annotate each file with its top authors and last-modified date from git blame")
+	viper.BindPFlag("blame_summary", rootCmd.Flags().Lookup("blame-summary"))
+	rootCmd.Flags().StringVar(&gitContextFormats, "git-context-formats", "", "Output formats that include --git-log/--blame-summary sections (comma-separated: text, xml, markdown, json, jsonl, pdf; default all)")
+	viper.BindPFlag("git_context_formats", rootCmd.Flags().Lookup("git-context-formats"))
+
+	// Archives
+	rootCmd.Flags().Int64Var(&maxArchiveSize, "max-archive-size", defaultMaxArchiveSize, "Maximum size in bytes of an archive input, downloaded and extracted (0 for no limit)")
+	viper.BindPFlag("max_archive_size", rootCmd.Flags().Lookup("max-archive-size"))
+	rootCmd.Flags().IntVar(&maxArchiveEntries, "max-archive-entries", defaultMaxArchiveEntries, "Maximum number of entries in an archive input (0 for no limit)")
+	viper.BindPFlag("max_archive_entries", rootCmd.Flags().Lookup("max-archive-entries"))
+
 	// Web Specific
 	rootCmd.Flags().BoolVar(&traverseLinks, "traverse-links", false, "Traverse links when processing URLs")
 	viper.BindPFlag("traverse_links", rootCmd.Flags().Lookup("traverse-links"))
@@ -379,6 +599,7 @@ func init() {
 	viper.SetDefault("max_size", 10485760) // 10MB
 	viper.SetDefault("max_depth", 20)
 	viper.SetDefault("default_output_format", "both")
+	viper.SetDefault("format", "text")
 	viper.SetDefault("default_tokenizer", "tiktoken")
 	viper.SetDefault("default_tokenizer_model", "") // Rely on tokenizer specific defaults
 	viper.SetDefault("traverse_links", false)
@@ -446,6 +667,7 @@ func initConfig() {
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		recordConfigLayer("global config", viper.ConfigFileUsed(), nil)
 	} else {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			// Config file not found; ignore error if desired
@@ -456,17 +678,39 @@ func initConfig() {
 		}
 	}
 
-	// After loading config, potentially update flag variables if needed?
-	// Cobra/Viper binding should handle this - the flag variables like `maxDepth`
-	// should now hold the final value from Default < Config < Env < Flag.
-	// Example: Update excludePatterns based on combined sources if needed
+	// A project's .iris.toml (found by walking up from the first local input) overrides the global config
+	if projectFile := findProjectConfig(projectConfigStart(rootCmd.Flags().Args())); projectFile != "" {
+		if applied, err := mergeProjectConfig(projectFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading project config file %s: %s\n", projectFile, err)
+		} else {
+			fmt.Fprintln(os.Stderr, "Using project config file:", projectFile)
+			recordConfigLayer("project config", projectFile, func(key string) bool {
+				top, _, _ := strings.Cut(key, ".")
+				return applied[top]
+			})
+		}
+	}
+
+	// Bound flags only report viper values when asked through viper, so copy config and
+	// environment values into flags the user didn't set: Default < Config < Env < Flag.
+	applyConfigToFlags()
+
 	// The `default_excludes` from config will set the default for the `exclude` flag.
-	// If `-e` is used, it overrides. If neither, the flag default ("" initially) is used.
-	// Let's explicitly load the excludes from viper IF the flag wasn't set.
+	// If `-e` is used, it overrides. If neither, the built-in default_excludes are used.
 	if !rootCmd.Flags().Changed("exclude") {
 		excludePatterns = strings.Join(viper.GetStringSlice("default_excludes"), ",")
 	}
-	// Similar logic could apply to other defaults if direct variable access is preferred over flags.
+
+	if debugConfig {
+		printConfigDebug()
+	}
+}
+
+// initLogging routes progress messages away from stdout when structured output is requested.
+func initLogging() {
+	if resultFormat == "json" || resultFormat == "jsonl" {
+		logOut = os.Stderr
+	}
 }
 
 // initLanguages loads the language definitions.
@@ -485,6 +729,7 @@ func initLanguages() {
 func main() {
 	// initConfig() is called via cobra.OnInitialize(initConfig)
 	rootCmd.Execute()
+	os.Exit(exitCode)
 }
 
 // Helper function to check if a path is a directory (used in output generation)
@@ -496,35 +741,14 @@ func isDir(path string) bool {
 	return info.IsDir()
 }
 
-// tokenWorker now accepts the Tokenizer interface.
-func tokenWorker(tk Tokenizer, jobs <-chan FileInfo, results chan<- FileInfo, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for file := range jobs {
-		if file.IsDir {
-			results <- file
-			continue
-		}
-
-		var content []byte
-		var readErr error
-
-		if file.Content != nil { // Use pre-loaded content (from web processing)
-			content = file.Content
-		} else { // Read from disk for local files/git files
-			content, readErr = os.ReadFile(file.Path)
-		}
-
-		if readErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: worker could not read file %s: %v\n", file.Path, readErr)
-			file.Error = readErr
-		} else if len(content) > 0 { // Only count tokens if content is available and read successfully
-			// Use the interface method to count tokens
-			file.TokenCount = tk.CountTokens(string(content))
-		} else {
-			file.TokenCount = 0
+// containsString reports whether value is in list.
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
 		}
-		results <- file
 	}
+	return false
 }
 
 // isWebURL checks if the input string is an HTTP/HTTPS URL.
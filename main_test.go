@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setFlag sets a flag variable for the duration of a test.
+func setFlag[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// writeTestFile writes content to path, creating its parent directories.
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// byteTokenizer counts one token per byte.
+type byteTokenizer struct{}
+
+func (byteTokenizer) CountTokens(text string) int { return len(text) }
+func (byteTokenizer) Close()                      {}
+
+// storedTestFiles adds the content of each file to a new contentStore, in order.
+func storedTestFiles(t *testing.T, files []FileInfo, contents []string) ([]FileInfo, *contentStore) {
+	t.Helper()
+	store, err := newContentStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	for i, content := range contents {
+		if files[i].Emitted, err = store.add([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files, store
+}
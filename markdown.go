@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// markdownFence returns a backtick fence long enough that no backtick run inside
+// content can terminate it early (CommonMark requires the closing fence to be at
+// least as long as the opening one).
+func markdownFence(content string) string {
+	longest, current := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			current++
+			if current > longest {
+				longest = current
+			}
+		} else {
+			current = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// markdownInfoString converts a language name into a fence info string (e.g. "Objective-C" -> "objective-c").
+func markdownInfoString(lang string) string {
+	return strings.ReplaceAll(strings.ToLower(lang), " ", "-")
+}
+
+// writeMarkdownCodeBlock writes content inside a fenced code block tagged with lang.
+func writeMarkdownCodeBlock(builder io.StringWriter, content, lang string) {
+	fence := markdownFence(content)
+	builder.WriteString(fence)
+	builder.WriteString(markdownInfoString(lang))
+	builder.WriteString("\n")
+	builder.WriteString(content)
+	if len(content) > 0 && !strings.HasSuffix(content, "\n") {
+		builder.WriteString("\n")
+	}
+	builder.WriteString(fence)
+	builder.WriteString("\n")
+}
+
+// printMarkdown writes the 'markdown' output format: the tree in a fenced block,
+// then a "## path" heading and language-tagged fenced code block per file, then the summary.
+func printMarkdown(w io.Writer, files []FileInfo, tree string, summary string, includeTokens bool, langData *LoadedLanguageData, contents *contentStore) error {
+	out := bufio.NewWriter(w)
+
+	out.WriteString("# Directory Structure\n\n")
+	writeMarkdownCodeBlock(out, tree, "text")
+	out.WriteString("\n")
+
+	sort.Slice(files, func(i, j int) bool { // Sort by path for consistent output
+		return files[i].Path < files[j].Path
+	})
+
+	for _, file := range emittedFiles(files) { // Skipped files only appear in the tree
+		content, readErr := contents.content(file)
+		writeMarkdownFile(out, file, content, readErr, includeTokens, langData)
+	}
+
+	// Render the summary lines as a list under its own heading
+	out.WriteString("## Summary\n\n")
+	for _, line := range strings.Split(strings.TrimRight(summary, "\n"), "\n") {
+		if line == "" || strings.HasPrefix(line, "---") {
+			continue // Skip the plain-text "--- Summary ---" title
+		}
+		out.WriteString(fmt.Sprintf("- %s\n", line))
+	}
+
+	return out.Flush()
+}
+
+// writeMarkdownFile writes the heading, token count and fenced emitted content of a single file.
+func writeMarkdownFile(out io.StringWriter, file FileInfo, content []byte, readErr error, includeTokens bool, langData *LoadedLanguageData) {
+	out.WriteString(fmt.Sprintf("## %s\n\n", file.Path))
+	if includeTokens {
+		if file.Error != nil {
+			out.WriteString(fmt.Sprintf("Tokens: Error (%v)\n\n", file.Error))
+		} else if file.OriginalTokenCount > 0 {
+			out.WriteString(fmt.Sprintf("Tokens: %d (original: %d)\n\n", file.TokenCount, file.OriginalTokenCount))
+		} else {
+			out.WriteString(fmt.Sprintf("Tokens: %d\n\n", file.TokenCount))
+		}
+	}
+
+	if file.Blame != nil && len(file.Blame.Authors) > 0 {
+		out.WriteString(fmt.Sprintf("Authors: %s\n\n", formatBlameSummary(file.Blame)))
+	}
+	if len(file.History) > 0 {
+		out.WriteString("Recent commits:\n\n")
+		writeMarkdownCodeBlock(out, formatCommits(file.History, ""), "text")
+		out.WriteString("\n")
+	}
+
+	if readErr != nil {
+		out.WriteString(fmt.Sprintf("Error reading file: %v\n\n", readErr))
+		return
+	}
+
+	// Diffs are tagged "diff", web pages "markdown", binaries get no tag
+	writeMarkdownCodeBlock(out, string(content), languageForFile(file, langData))
+	out.WriteString("\n")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownFence(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"", "```"},
+		{"plain text", "```"},
+		{"inline `code` and ``more``", "```"},
+		{"```go\nfmt.Println()\n```", "````"},
+		{"a ```` b ``` c", "`````"},
+		{"``\n``", "```"}, // Runs are broken by other characters
+	}
+	for _, tt := range tests {
+		if got := markdownFence(tt.content); got != tt.want {
+			t.Errorf("markdownFence(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestWriteMarkdownCodeBlock(t *testing.T) {
+	tests := []struct {
+		content, lang string
+		want          string
+	}{
+		{"package main\n", "Go", "```go\npackage main\n```\n"},
+		{"no newline", "Objective-C", "```objective-c\nno newline\n```\n"},
+		{"", "", "```\n```\n"},
+		{"```\nnested\n```\n", "Markdown", "````markdown\n```\nnested\n```\n````\n"},
+		{"x", "Vim Script", "```vim-script\nx\n```\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		writeMarkdownCodeBlock(&b, tt.content, tt.lang)
+		if got := b.String(); got != tt.want {
+			t.Errorf("writeMarkdownCodeBlock(%q, %q) = %q, want %q", tt.content, tt.lang, got, tt.want)
+		}
+	}
+}
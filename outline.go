@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// outlinePrinter formats declarations the way gofmt does.
+var outlinePrinter = printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+
+// outlineContent reduces supported source files to their declarations for --outline.
+// Only Go is supported; other files (and Go files that fail to parse) keep their
+// full content. It reports whether the content changed.
+func outlineContent(path string, content []byte) ([]byte, bool) {
+	if strings.ToLower(filepath.Ext(path)) != ".go" || isWebURL(path) {
+		return content, false
+	}
+	outline, err := outlineGo(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not outline %s, keeping full content: %v\n", path, err)
+		return content, false
+	}
+	return outline, true
+}
+
+// outlineGo renders the package clause, imports, type declarations and function/method
+// signatures of a Go source file, each with its doc comment. Function bodies and all
+// other comments are dropped.
+func outlineGo(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if file.Doc != nil {
+		writeCommentGroup(&buf, file.Doc)
+	}
+	fmt.Fprintf(&buf, "package %s\n", file.Name.Name)
+
+	for _, decl := range file.Decls {
+		var node ast.Node
+		var doc *ast.CommentGroup
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.IMPORT && d.Tok != token.TYPE {
+				continue // Constants and variables are not part of the outline
+			}
+			gen := *d
+			gen.Doc = nil
+			node, doc = &gen, d.Doc
+		case *ast.FuncDecl:
+			signature := *d
+			signature.Doc = nil
+			signature.Body = nil // Printing a FuncDecl without a body yields just the signature
+			node, doc = &signature, d.Doc
+		default:
+			continue
+		}
+
+		// The doc comment is written as is: the printer would reformat it, as gofmt
+		// does. Of the other comments only those inside the declaration (field
+		// comments of types) are kept; comments in function bodies fall outside it.
+		var comments []*ast.CommentGroup
+		for _, group := range file.Comments {
+			if group.Pos() >= node.Pos() && group.End() <= node.End() {
+				comments = append(comments, group)
+			}
+		}
+
+		buf.WriteString("\n")
+		if doc != nil {
+			writeCommentGroup(&buf, doc)
+		}
+		if err := outlinePrinter.Fprint(&buf, fset, &printer.CommentedNode{Node: node, Comments: comments}); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// writeCommentGroup writes a comment group verbatim, one comment per line.
+func writeCommentGroup(buf *bytes.Buffer, group *ast.CommentGroup) {
+	for _, comment := range group.List {
+		buf.WriteString(comment.Text)
+		buf.WriteString("\n")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestOutlineGo(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "functions",
+			src:  "package x\n\n// Add adds.\nfunc Add(a, b int) int {\n\t// inside\n\treturn a + b\n}\n\nfunc init() { println() }\n",
+			want: "package x\n\n// Add adds.\nfunc Add(a, b int) int\n\nfunc init()\n",
+		},
+		{
+			name: "methods and generics",
+			src:  "package x\n\n// Len is a method.\nfunc (s *Stack[T]) Len() int { return len(s.items) }\n\nfunc Map[T, U any](xs []T, f func(T) U) []U {\n\treturn nil\n}\n",
+			want: "package x\n\n// Len is a method.\nfunc (s *Stack[T]) Len() int\n\nfunc Map[T, U any](xs []T, f func(T) U) []U\n",
+		},
+		{
+			name: "types keep field comments",
+			src:  "package x\n\n// T is a type.\ntype T struct {\n\t// Name names it.\n\tName string // trailing\n\tn    int\n}\n\ntype (\n\t// A is a.\n\tA int\n\tB = string\n)\n",
+			want: "package x\n\n// T is a type.\ntype T struct {\n\t// Name names it.\n\tName string // trailing\n\tn    int\n}\n\ntype (\n\t// A is a.\n\tA int\n\tB = string\n)\n",
+		},
+		{
+			name: "interfaces",
+			src:  "package x\n\ntype Reader interface {\n\t// Read reads.\n\tRead(p []byte) (int, error)\n}\n",
+			want: "package x\n\ntype Reader interface {\n\t// Read reads.\n\tRead(p []byte) (int, error)\n}\n",
+		},
+		{
+			name: "constants and variables are dropped",
+			src:  "package x\n\n// Answer is the answer.\nconst Answer = 42\n\nconst (\n\tA = iota\n\tB\n)\n\nvar (\n\t// v is a var.\n\tv = 1\n\tw = 2\n)\n\nfunc F() {}\n",
+			want: "package x\n\nfunc F()\n",
+		},
+		{
+			name: "imports",
+			src:  "package x\n\nimport \"os\"\n\nimport (\n\t\"fmt\" // printing\n\t_ \"embed\"\n)\n",
+			want: "package x\n\nimport \"os\"\n\nimport (\n\t\"fmt\" // printing\n\t_ \"embed\"\n)\n",
+		},
+		{
+			name: "doc comments are kept verbatim",
+			src:  "// Package x does things.\n//\n//   indented\npackage x\n\n//go:generate echo hi\n// T is a type.\n//\n//   - not reformatted\ntype T int\n\n/* Block doc. */\nfunc F() {}\n",
+			want: "// Package x does things.\n//\n//   indented\npackage x\n\n//go:generate echo hi\n// T is a type.\n//\n//   - not reformatted\ntype T int\n\n/* Block doc. */\nfunc F()\n",
+		},
+		{
+			name: "other comments are dropped",
+			src:  "package x\n\n// Floating comment.\n\nfunc F() {\n\t// inside\n}\n\n// Trailing comment.\n",
+			want: "package x\n\nfunc F()\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := outlineGo([]byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("outlineGo() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutlineContent(t *testing.T) {
+	goSrc := []byte("package x\n\nfunc F() {\n\treturn\n}\n")
+	if got, changed := outlineContent("x.go", goSrc); !changed || string(got) != "package x\n\nfunc F()\n" {
+		t.Errorf("outlineContent(x.go) = %q, %v", got, changed)
+	}
+	// Other languages and Go files that fail to parse keep their content
+	for _, tt := range []struct{ path, src string }{
+		{"x.py", "def f():\n    return 1\n"},
+		{"broken.go", "package x\n\nfunc {\n"},
+	} {
+		if got, changed := outlineContent(tt.path, []byte(tt.src)); changed || string(got) != tt.src {
+			t.Errorf("outlineContent(%s) = %q, %v, want the content unchanged", tt.path, got, changed)
+		}
+	}
+}
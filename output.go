@@ -1,19 +1,41 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"text/template"
 )
 
+// validOutputFormats lists the values accepted by --output / default_output_format.
+var validOutputFormats = []string{"tree", "files", "both", "xml", "markdown"}
+
+// isValidOutputFormat reports whether format is one of validOutputFormats.
+func isValidOutputFormat(format string) bool {
+	return containsString(validOutputFormats, format)
+}
+
+// formatShowsTree reports whether the given output format includes the tree view.
+func formatShowsTree(format string) bool {
+	return format == "tree" || format == "both" || format == "xml" || format == "markdown"
+}
+
+// formatShowsFiles reports whether the given output format includes file contents.
+func formatShowsFiles(format string) bool {
+	return format == "files" || format == "both" || format == "xml" || format == "markdown"
+}
+
 // Node represents an entry in the directory tree structure.
 type Node struct {
 	Name     string
 	Path     string
 	IsDir    bool
-	Size     int64 // Relevant for files
+	Size     int64  // Relevant for files
+	Note     string // Marker shown after the name, e.g. "[omitted: token budget]"
 	Children []*Node
 }
 
@@ -35,15 +57,19 @@ func buildTree(files []FileInfo, rootPath string) *Node {
 		parentDir := filepath.Dir(cleanPath)
 		baseName := filepath.Base(cleanPath)
 
-		parentNode, exists := nodes[parentDir]
-		if !exists {
-			// This might happen if a directory itself was filtered out, but not its contents.
-			// Or if the input list doesn't represent a fully connected tree.
-			// For simplicity, let's assume parents exist or create intermediate ones if necessary.
-			// A more robust approach might be needed depending on filtering behavior.
+		parentNode := ensureDirNode(nodes, parentDir)
+		if parentNode == nil {
+			// The file lies outside the root (e.g. mixed inputs), so it cannot be placed in this tree.
 			fmt.Fprintf(os.Stderr, "Warning: Parent node not found for %s, skipping in tree view.\n", cleanPath)
 			continue
-			// Alternatively, create missing parent nodes recursively upwards?
+		}
+
+		if file.IsDir {
+			if existing, ok := nodes[cleanPath]; ok {
+				existing.Size = file.Size // Directory already created as an intermediate node
+				existing.Note = treeNote(file)
+				continue
+			}
 		}
 
 		node := &Node{
@@ -51,6 +77,7 @@ func buildTree(files []FileInfo, rootPath string) *Node {
 			Path:  cleanPath,
 			IsDir: file.IsDir,
 			Size:  file.Size,
+			Note:  treeNote(file),
 		}
 
 		parentNode.Children = append(parentNode.Children, node)
@@ -65,6 +92,59 @@ func buildTree(files []FileInfo, rootPath string) *Node {
 	return root
 }
 
+// treeNote returns the markers displayed next to a file in the tree view:
+// its change status (with --changed/--since) followed by why content is withheld.
+func treeNote(file FileInfo) string {
+	var notes []string
+	if file.Submodule != "" {
+		notes = append(notes, "[submodule @ "+shortSHA(file.Submodule)+"]")
+	}
+	if file.ChangeStatus != "" {
+		notes = append(notes, "["+file.ChangeStatus+"]")
+	}
+	switch {
+	case file.SkipReason == deletedSkipReason && file.ChangeStatus == "D":
+		// [D] already says it
+	case file.SkipReason == submoduleSkipReason && file.Submodule != "":
+		// [submodule @ sha] already says it
+	case file.SkipReason != "":
+		notes = append(notes, "["+file.SkipReason+"]")
+	case file.Binary:
+		notes = append(notes, "["+binarySkipReason+"]") // Included via --include-binary
+	}
+	return strings.Join(notes, " ")
+}
+
+// shortSHA abbreviates a commit SHA the way git log --oneline does.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// ensureDirNode returns the directory node for dirPath, creating any missing
+// intermediate directories between it and the root. walkDirectory only reports
+// files, so the directories that contain them are synthesized here.
+// It returns nil if dirPath is not inside the root.
+func ensureDirNode(nodes map[string]*Node, dirPath string) *Node {
+	if node, ok := nodes[dirPath]; ok {
+		return node
+	}
+	parentDir := filepath.Dir(dirPath)
+	if parentDir == dirPath || dirPath == "." || dirPath == string(filepath.Separator) {
+		return nil // Walked past the root without finding it
+	}
+	parentNode := ensureDirNode(nodes, parentDir)
+	if parentNode == nil {
+		return nil
+	}
+	node := &Node{Name: filepath.Base(dirPath), Path: dirPath, IsDir: true}
+	parentNode.Children = append(parentNode.Children, node)
+	nodes[dirPath] = node
+	return node
+}
+
 // sortChildren recursively sorts the children of a node alphabetically.
 func sortChildren(node *Node) {
 	if !node.IsDir || len(node.Children) == 0 {
@@ -107,6 +187,10 @@ func printNode(builder *strings.Builder, children []*Node, prefix string) {
 		builder.WriteString(prefix)
 		builder.WriteString(connector)
 		builder.WriteString(node.Name)
+		if node.Note != "" {
+			builder.WriteString(" ")
+			builder.WriteString(node.Note)
+		}
 		// Optionally add size or other info here
 		// if !node.IsDir {
 		//  builder.WriteString(fmt.Sprintf(" (%d bytes)", node.Size))
@@ -119,51 +203,255 @@ func printNode(builder *strings.Builder, children []*Node, prefix string) {
 	}
 }
 
-// printFiles generates the string representation for the 'files' output format.
-func printFiles(files []FileInfo, includeTokens bool) string {
+// treeRootFor builds the tree for a single directory input, or returns nil when
+// the inputs (multiple paths, a single file, URLs) do not form one tree.
+func treeRootFor(files []FileInfo, inputPaths []string) *Node {
+	if len(inputPaths) != 1 {
+		return nil
+	}
+	if root, ok := inputTreeRoots[inputPaths[0]]; ok { // Cloned repository or archive, paths are relative to its root
+		return buildTree(files, root)
+	}
+	if isDir(inputPaths[0]) { // Check original single input path type
+		return buildTree(files, inputPaths[0])
+	}
+	return nil
+}
+
+// renderTreeSection returns the tree view for the processed files. A real tree is
+// only drawn for a single directory input; otherwise the files are listed flat.
+func renderTreeSection(files []FileInfo, inputPaths []string) string {
 	var builder strings.Builder
-	sort.Slice(files, func(i, j int) bool { // Sort by path for consistent output
-		return files[i].Path < files[j].Path
-	})
+	if rootNode := treeRootFor(files, inputPaths); rootNode != nil {
+		builder.WriteString(printTree(rootNode))
+	} else if len(files) > 0 { // Check if any files were processed
+		// If multiple inputs or single file input, show the message
+		builder.WriteString("Tree view generated for single directory input only.\nFiles found:\n")
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].Path < files[j].Path
+		})
+		for _, file := range files {
+			if note := treeNote(file); note != "" {
+				builder.WriteString(fmt.Sprintf("- %s %s\n", file.Path, note))
+			} else {
+				builder.WriteString(fmt.Sprintf("- %s\n", file.Path))
+			}
+		}
+	}
+	return builder.String()
+}
 
-	for _, file := range files {
-		if file.IsDir {
-			continue // Skip directories for 'files' output format
+// formatSummary returns the "--- Summary ---" block shared by the text based formats.
+func formatSummary(summary Summary, includeTokens bool) string {
+	var builder strings.Builder
+	builder.WriteString("--- Summary ---\n")
+	for _, source := range summary.GitSources {
+		if source.Ref != "" {
+			builder.WriteString(fmt.Sprintf("Git source: %s @ %s (commit %s)\n", source.URL, source.Ref, source.Commit))
+		} else {
+			builder.WriteString(fmt.Sprintf("Git source: %s (commit %s)\n", source.URL, source.Commit))
+		}
+		if source.Subdir != "" {
+			builder.WriteString(fmt.Sprintf("Git subdirectory: %s\n", source.Subdir))
+		}
+	}
+	builder.WriteString(fmt.Sprintf("Total files processed: %d\n", summary.TotalFiles))
+	builder.WriteString(fmt.Sprintf("Total size: %d bytes\n", summary.TotalSize))
+	if includeTokens {
+		builder.WriteString(fmt.Sprintf("Total tokens: %d\n", summary.TotalTokens))
+	}
+	if summary.FailedPaths > 0 {
+		builder.WriteString(fmt.Sprintf("Paths failed to process: %d\n", summary.FailedPaths))
+	}
+	if len(summary.Compression) > 0 {
+		before, after := 0, 0
+		for _, stat := range summary.Compression {
+			before += stat.Before
+			after += stat.After
+		}
+		builder.WriteString(fmt.Sprintf("Compression: %d -> %d tokens (%s saved) across %d file(s)\n", before, after, percentSaved(before, after), len(summary.Compression)))
+		for _, stat := range summary.Compression {
+			builder.WriteString(fmt.Sprintf("  - %s: %d -> %d\n", stat.Path, stat.Before, stat.After))
+		}
+	}
+	if len(summary.Secrets) > 0 {
+		verb := "found"
+		if summary.SecretsRedacted {
+			verb = "redacted"
+		}
+		builder.WriteString(fmt.Sprintf("Secrets %s: %d (%s)\n", verb, countSecrets(summary.Secrets), formatSecretCounts(summary.Secrets)))
+	}
+	for _, history := range summary.History {
+		builder.WriteString(fmt.Sprintf("Recent commits in %s:\n", history.Repository))
+		builder.WriteString(formatCommits(history.Commits, "  "))
+	}
+	if summary.TokenBudget > 0 {
+		builder.WriteString(fmt.Sprintf("Token budget: %d\n", summary.TokenBudget))
+	}
+	if len(summary.OmittedFiles) > 0 {
+		builder.WriteString(fmt.Sprintf("Files omitted to fit token budget: %d (%d tokens)\n", len(summary.OmittedFiles), summary.OmittedTokens))
+		for _, path := range summary.OmittedFiles {
+			builder.WriteString(fmt.Sprintf("  - %s\n", path))
 		}
+	}
+	return builder.String()
+}
 
-		builder.WriteString(fmt.Sprintf("File: %s\n", file.Path))
+// percentSaved formats the relative reduction from before to after, e.g. "25.0%".
+func percentSaved(before, after int) string {
+	if before == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(before-after)*100/float64(before))
+}
+
+// summarizeFiles fills in the totals, compression results and secret counts of
+// the files whose content is emitted. Repository history printed in the summary
+// counts towards the total tokens.
+func summarizeFiles(summary *Summary, files []FileInfo, tk Tokenizer) {
+	includeTokens := !disableTokens && tk != nil
+	for _, file := range files {
+		if file.IsDir || file.SkipReason != "" {
+			continue
+		}
+		summary.TotalFiles++
+		summary.TotalSize += file.Size
 		if includeTokens {
-			if file.Error != nil {
-				builder.WriteString(fmt.Sprintf("Tokens: Error (%v)\n", file.Error)) // Indicate error during token count
-			} else {
-				builder.WriteString(fmt.Sprintf("Tokens: %d\n", file.TokenCount))
+			summary.TotalTokens += file.TokenCount
+		}
+		if compressMode && includeTokens && file.Compression != nil {
+			summary.Compression = append(summary.Compression, *file.Compression)
+		}
+		for rule, n := range file.Secrets {
+			if summary.Secrets == nil {
+				summary.Secrets = make(map[string]int)
 			}
+			summary.Secrets[rule] += n
 		}
-		builder.WriteString(strings.Repeat("=", 50))
-		builder.WriteString("\n")
-
-		// Read file content OR use pre-loaded web content
-		var contentToPrint []byte
-		var readErr error
-		if file.Content != nil { // Use content if already loaded (from web)
-			contentToPrint = file.Content
-		} else { // Otherwise, read from disk
-			contentToPrint, readErr = os.ReadFile(file.Path)
+	}
+	if includeTokens {
+		for _, history := range summary.History {
+			summary.TotalTokens += tk.CountTokens(formatCommits(history.Commits, "  "))
 		}
+	}
+	sort.Slice(summary.Compression, func(i, j int) bool {
+		return summary.Compression[i].Path < summary.Compression[j].Path
+	})
+	summary.SecretsRedacted = redactSecrets && len(summary.Secrets) > 0
+}
+
+// outputRenderer writes the output selected by --format, --output and --template.
+// The token budget measures records through it too, so it charges exactly what
+// the selected format emits (escaping, attributes, fences and field names included).
+type outputRenderer struct {
+	template *template.Template // Layout of text output
+	inputs   []string
+	langData *LoadedLanguageData
+	contents *contentStore // Emitted content of the processed files
+}
+
+// write renders the complete output for files and summary to w.
+func (r outputRenderer) write(w io.Writer, files []FileInfo, summary Summary) error {
+	switch {
+	case resultFormat == "json":
+		return printJSON(w, files, r.inputs, summary, r.langData, r.contents)
+	case resultFormat == "jsonl":
+		return printJSONL(w, files, r.inputs, summary, r.langData, r.contents)
+	case outputFormat == "xml":
+		return printXML(w, files, renderTreeSection(files, r.inputs), formatSummary(summary, !disableTokens), !disableTokens, r.langData, r.contents)
+	case outputFormat == "markdown":
+		return printMarkdown(w, files, renderTreeSection(files, r.inputs), formatSummary(summary, !disableTokens), !disableTokens, r.langData, r.contents)
+	}
+	// Plain text output is rendered through the built-in or user-supplied template
+	return r.template.Execute(w, newTemplateData(files, r.inputs, summary, r.langData, r.contents))
+}
+
+// recordTokens returns the tokens an emitted file adds to the output: its record
+// as the format renders it around empty content, plus the content as the format
+// writes it (counted while processing, see outputContentTokens).
+func (r outputRenderer) recordTokens(file FileInfo, tk Tokenizer) int {
+	return r.recordFrameTokens(file, tk) + file.OutputTokens
+}
 
-		if readErr != nil {
-			// If token counting failed due to read error, file.Error might already be set.
-			// We still report the error here in the content section.
-			builder.WriteString(fmt.Sprintf("Error reading file: %v\n", readErr))
+// recordFrameTokens returns the tokens of a file's record with empty content:
+// its header and the separators and wrapping around the content.
+func (r outputRenderer) recordFrameTokens(file FileInfo, tk Tokenizer) int {
+	var record strings.Builder
+	switch {
+	case resultFormat == "json" || resultFormat == "jsonl":
+		jf := newJSONFile(file, r.langData)
+		if jsonShowsContent(file) {
+			jf.setContent(nil, nil)
+		}
+		encoder := json.NewEncoder(&record)
+		encoder.SetEscapeHTML(false)
+		if resultFormat == "json" {
+			encoder.SetIndent("    ", "  ")
+			record.WriteString("    ")
 		} else {
-			builder.Write(contentToPrint)
+			jf.Type = "file"
+		}
+		if err := encoder.Encode(jf); err != nil {
+			return 0 // Not emitted either
+		}
+		record.WriteString(",") // Separator between records of the json files array
+	case outputFormat == "xml":
+		writeXMLFile(&record, file, nil, nil, !disableTokens, r.langData)
+	case outputFormat == "markdown":
+		writeMarkdownFile(&record, file, nil, nil, !disableTokens, r.langData)
+	default:
+		// A template can put anything around a file, so compare its output with and without the file
+		data := TemplateData{OutputFormat: outputFormat, ShowFiles: formatShowsFiles(outputFormat), TokensEnabled: !disableTokens}
+		var without strings.Builder
+		if err := r.template.Execute(&without, data); err != nil {
+			return 0
 		}
+		data.Files = []*TemplateFile{newTemplateFile(file, r.langData)} // Without a store its content is empty
+		if err := r.template.Execute(&record, data); err != nil {
+			return 0
+		}
+		return max(tk.CountTokens(record.String())-tk.CountTokens(without.String()), 0)
+	}
+	return tk.CountTokens(record.String())
+}
 
-		// Ensure consistent line breaks after content
-		if len(contentToPrint) > 0 && contentToPrint[len(contentToPrint)-1] != '\n' {
-			builder.WriteString("\n")
+// omitLineTokens returns the tokens a file adds to the summary's list of files
+// omitted by the token budget.
+func (r outputRenderer) omitLineTokens(path string, tk Tokenizer) int {
+	line := fmt.Sprintf("  - %s\n", path)
+	switch {
+	case resultFormat == "json" || resultFormat == "jsonl":
+		encoded, _ := json.Marshal(path)
+		line = fmt.Sprintf("      %s,\n", encoded)
+	case outputFormat == "xml":
+		line = xmlTextEscaper.Replace(line)
+	case outputFormat == "markdown":
+		line = "- " + line // Summary lines are rendered as a list
+	}
+	return tk.CountTokens(line)
+}
+
+// outputContentTokens returns the tokens of a file's emitted content as the
+// selected output format writes it: escaped in XML and JSON strings, fenced in
+// Markdown, as is in text. It is 0 when the format doesn't show content.
+func outputContentTokens(file FileInfo, content []byte, tk Tokenizer) int {
+	if !formatShowsFiles(outputFormat) {
+		return 0 // Only listed (or described, in JSON)
+	}
+	var written strings.Builder
+	switch {
+	case resultFormat == "json" || resultFormat == "jsonl":
+		encoder := json.NewEncoder(&written)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(string(content)); err != nil {
+			return 0
 		}
-		builder.WriteString("\n") // Add blank line between files
+	case outputFormat == "xml":
+		written.WriteString(xmlTextEscaper.Replace(string(content)))
+	case outputFormat == "markdown":
+		writeMarkdownCodeBlock(&written, string(content), languageForFile(file, langData))
+	default:
+		return file.TokenCount
 	}
-	return builder.String()
+	return tk.CountTokens(written.String())
 }
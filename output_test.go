@@ -0,0 +1,32 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildTreeNestedDirectories(t *testing.T) {
+	// Walks only report files, so the directories between them and the root are synthesized
+	root := filepath.Join("work", "proj")
+	files := []FileInfo{
+		{Path: filepath.Join(root, "x.go")},
+		{Path: filepath.Join(root, "a", "b", "c.go")},
+		{Path: filepath.Join(root, "a", "b.go")},
+		{Path: filepath.Join(root, "docs"), IsDir: true},
+		{Path: filepath.Join(root, "docs", "guide", "intro.md")},
+		{Path: filepath.Join("elsewhere", "y.go")}, // Outside the root, left out of the tree
+	}
+	want := `proj
+├── a
+│   ├── b
+│   │   └── c.go
+│   └── b.go
+├── docs
+│   └── guide
+│       └── intro.md
+└── x.go
+`
+	if got := printTree(buildTree(files, root)); got != want {
+		t.Errorf("printTree() =\n%s\nwant\n%s", got, want)
+	}
+}
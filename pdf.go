@@ -22,9 +22,10 @@ const (
 )
 
 // generatePDF takes the collected FileInfo and Summary, generates syntax-highlighted
-// PDF output according to the selected format (tree, files, both).
-func generatePDF(files []FileInfo, summary Summary, outputFormat string, langData *LoadedLanguageData, outputPath string) error {
-	fmt.Printf("Generating PDF output at: %s (Format: %s)\n", outputPath, outputFormat)
+// PDF output according to the selected format (tree, files, both). The xml format
+// has no PDF equivalent and is rendered like "both".
+func generatePDF(files []FileInfo, summary Summary, outputFormat string, langData *LoadedLanguageData, contents *contentStore, outputPath string) error {
+	fmt.Fprintf(logOut, "Generating PDF output at: %s (Format: %s)\n", outputPath, outputFormat)
 
 	pdf := gofpdf.New("P", "mm", "A4", "") // Portrait, mm, A4, default font dir
 	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
@@ -39,7 +40,7 @@ func generatePDF(files []FileInfo, summary Summary, outputFormat string, langDat
 	}
 
 	// --- Output Tree (if requested) ---
-	if outputFormat == "tree" || outputFormat == "both" {
+	if formatShowsTree(outputFormat) {
 		// For the tree, we don't have syntax highlighting, just print the tree structure.
 		// Assuming the first input was a directory if tree format makes sense.
 		// Note: This assumes tree generation is still relevant based on input types.
@@ -54,17 +55,13 @@ func generatePDF(files []FileInfo, summary Summary, outputFormat string, langDat
 	}
 
 	// --- Output Files (if requested) ---
-	if outputFormat == "files" || outputFormat == "both" {
+	if formatShowsFiles(outputFormat) {
 		// Sort files for consistent output
 		sort.Slice(files, func(i, j int) bool {
 			return files[i].Path < files[j].Path
 		})
 
-		for _, file := range files {
-			if file.IsDir {
-				continue
-			}
-
+		for _, file := range emittedFiles(files) { // Skipped files only appear in the tree
 			// Add File Header
 			pdf.SetFont("Helvetica", "B", pdfFontSize+1)
 			pdf.SetTextColor(0, 0, 0)
@@ -77,6 +74,8 @@ func generatePDF(files []FileInfo, summary Summary, outputFormat string, langDat
 				tokenStr := ""
 				if file.Error != nil {
 					tokenStr = fmt.Sprintf("Tokens: Error (%v)", file.Error)
+				} else if file.OriginalTokenCount > 0 {
+					tokenStr = fmt.Sprintf("Tokens: %d (original: %d)", file.TokenCount, file.OriginalTokenCount)
 				} else {
 					tokenStr = fmt.Sprintf("Tokens: %d", file.TokenCount)
 				}
@@ -84,17 +83,16 @@ func generatePDF(files []FileInfo, summary Summary, outputFormat string, langDat
 				pdf.Ln(pdfLineHeight / 2)
 			}
 
+			if gitContext := formatGitContext(file); gitContext != "" {
+				pdf.SetFont("Helvetica", "", pdfFontSize-1)
+				pdf.MultiCell(pdfPageWidth-2*pdfMargin, pdfLineHeight, gitContext, "", "L", false)
+				pdf.Ln(pdfLineHeight / 2)
+			}
+
 			pdf.Line(pdfMargin, pdf.GetY(), pdfPageWidth-pdfMargin, pdf.GetY()) // Separator line
 			pdf.Ln(pdfLineHeight / 2)
 
-			// Get content (read again or use pre-loaded if available)
-			var content []byte
-			var readErr error
-			if file.Content != nil {
-				content = file.Content
-			} else {
-				content, readErr = os.ReadFile(file.Path)
-			}
+			content, readErr := contents.content(file)
 
 			if readErr != nil {
 				pdf.SetFont("Courier", "", pdfFontSize)
@@ -125,11 +123,8 @@ func generatePDF(files []FileInfo, summary Summary, outputFormat string, langDat
 	pdf.Ln(pdfLineHeight / 2)
 
 	pdf.SetFont("Helvetica", "", pdfFontSize)
-	summaryString := fmt.Sprintf("Total files processed: %d\nTotal size: %d bytes", summary.TotalFiles, summary.TotalSize)
-	if summary.TotalTokens > 0 { // Assuming token counting wasn't disabled
-		summaryString += fmt.Sprintf("\nTotal tokens: %d", summary.TotalTokens)
-	}
-	// Add failed paths count? summary doesn't store it currently.
+	// Reuse the text summary, dropping its "--- Summary ---" title which is printed above
+	summaryString := strings.TrimPrefix(formatSummary(summary, summary.TotalTokens > 0), "--- Summary ---\n")
 	pdf.MultiCell(pdfPageWidth-2*pdfMargin, pdfLineHeight, summaryString, "", "L", false)
 
 	// --- Save PDF ---
@@ -138,7 +133,7 @@ func generatePDF(files []FileInfo, summary Summary, outputFormat string, langDat
 		return fmt.Errorf("failed to save PDF to %s: %w", outputPath, err)
 	}
 
-	fmt.Printf("Successfully saved PDF to %s\n", outputPath)
+	fmt.Fprintf(logOut, "Successfully saved PDF to %s\n", outputPath)
 	return nil
 }
 
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+// processFiles reads every file once and runs the content stages on it (binary
+// and generated detection, diffs, redaction, transformations and token counting)
+// on numWorkers workers. Results are collected in the order of files, at most a
+// window of them ahead of the collector, and their emitted content is appended to
+// a contentStore, so memory is bounded by the window rather than the input and
+// output writes exactly the bytes that were scanned and counted. The caller closes
+// the store once the output is written.
+func processFiles(files []FileInfo, tk Tokenizer, numWorkers int) ([]FileInfo, *contentStore, error) {
+	store, err := newContentStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	workers := max(numWorkers, 1)
+	processed := make([]FileInfo, len(files))
+	err = processInOrder(len(files), workers, 2*workers, func(i int) processResult {
+		file, emitted := processFile(files[i], tk)
+		return processResult{file: file, emitted: emitted}
+	}, func(i int, result processResult) error {
+		var err error
+		result.file.Emitted, err = store.add(result.emitted)
+		processed[i] = result.file
+		return err
+	})
+	if err != nil {
+		store.Close()
+		return nil, nil, fmt.Errorf("failed to store processed content: %w", err)
+	}
+	return processed, store, nil
+}
+
+// processResult is a processed file and the content it emits.
+type processResult struct {
+	file    FileInfo
+	emitted []byte
+}
+
+// processInOrder runs work(i) for every i in [0, n) on workers goroutines and
+// calls emit with the results in order of i. At most window results are being
+// worked on or waiting for emit at any time. When emit fails, work still queued
+// is abandoned and the error is returned.
+func processInOrder(n, workers, window int, work func(i int) processResult, emit func(i int, result processResult) error) error {
+	// Each job gets a result channel, queued in order; the queue plus the result
+	// the collector is waiting on make up the window
+	pending := make(chan chan processResult, max(window, 1)-1)
+	done := make(chan struct{})
+	defer close(done)
+
+	jobs := make(chan func())
+	for w := 0; w < workers; w++ {
+		go func() {
+			for job := range jobs {
+				job()
+			}
+		}()
+	}
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for i := 0; i < n; i++ {
+			result := make(chan processResult, 1) // Workers never wait for the collector
+			select {
+			case pending <- result: // Blocks while the window is full
+			case <-done:
+				return
+			}
+			job := func() { result <- work(i) }
+			select {
+			case jobs <- job:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	i := 0
+	for result := range pending {
+		if err := emit(i, <-result); err != nil {
+			return err
+		}
+		i++
+	}
+	return nil
+}
+
+// processFile reads a single file and runs the content stages on it, returning
+// the processed file and the content it emits (nil when the content is withheld).
+func processFile(file FileInfo, tk Tokenizer) (FileInfo, []byte) {
+	if file.IsDir || file.SkipReason != "" { // Nothing to read (e.g. deleted files)
+		return file, nil
+	}
+	content, err := readFileContent(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: worker could not read file %s: %v\n", file.Path, err)
+		file.Error = err
+		return file, nil
+	}
+	// Transform the content (if requested) and count tokens on the result
+	return processContent(file, content, tk)
+}
+
+// readFileContent reads a file from its source. Files over --max-size are read
+// only as far as truncation keeps them.
+func readFileContent(file FileInfo) ([]byte, error) {
+	if exceedsMaxSize(file) {
+		return readTruncated(file)
+	}
+	return file.ReadContent()
+}
+
+// emittedFiles returns, in order, the files whose content is emitted: those that
+// are neither directories nor skipped.
+func emittedFiles(files []FileInfo) []FileInfo {
+	var emitted []FileInfo
+	for _, file := range files {
+		if !file.IsDir && file.SkipReason == "" {
+			emitted = append(emitted, file)
+		}
+	}
+	return emitted
+}
+
+// contentStore holds the emitted content of processed files in a temporary spill
+// file until the output is written.
+type contentStore struct {
+	file *os.File
+	size int64
+}
+
+// storedContent locates a file's emitted content in the contentStore.
+type storedContent struct {
+	offset int64
+	length int
+}
+
+// newContentStore creates an empty store backed by a temporary file.
+func newContentStore() (*contentStore, error) {
+	f, err := os.CreateTemp("", "iris-content-")
+	if err != nil {
+		return nil, err
+	}
+	return &contentStore{file: f}, nil
+}
+
+// add appends content to the store and returns where it was put.
+func (s *contentStore) add(content []byte) (storedContent, error) {
+	stored := storedContent{offset: s.size, length: len(content)}
+	if len(content) == 0 {
+		return stored, nil
+	}
+	if _, err := s.file.WriteAt(content, s.size); err != nil {
+		return storedContent{}, err
+	}
+	s.size += int64(len(content))
+	return stored, nil
+}
+
+// content returns the emitted content of a processed file, or the error that
+// kept it from being read. A nil store holds no content.
+func (s *contentStore) content(file FileInfo) ([]byte, error) {
+	if file.Error != nil {
+		return nil, file.Error
+	}
+	if s == nil || file.Emitted.length == 0 {
+		return nil, nil
+	}
+	content := make([]byte, file.Emitted.length)
+	if _, err := s.file.ReadAt(content, file.Emitted.offset); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// Close removes the store's spill file.
+func (s *contentStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// outputSink streams rendered output to its destination: the --file, stdout, or
+// (with --clipboard) a buffer copied to the clipboard once the output is complete.
+type outputSink struct {
+	*bufio.Writer
+	file      *os.File         // Set when writing to --file
+	clipboard *strings.Builder // Set when copying to the clipboard
+}
+
+// openOutputSink opens the destination selected by --file and --clipboard, defaulting to stdout.
+func openOutputSink() (*outputSink, error) {
+	switch {
+	case outputFile != "":
+		f, err := os.Create(outputFile)
+		if err != nil {
+			return nil, err
+		}
+		return &outputSink{Writer: bufio.NewWriter(f), file: f}, nil
+	case copyToClipboard:
+		buffer := &strings.Builder{}
+		return &outputSink{Writer: bufio.NewWriter(buffer), clipboard: buffer}, nil
+	}
+	return &outputSink{Writer: bufio.NewWriter(os.Stdout)}, nil
+}
+
+// Close flushes the output and finishes the destination: the file is closed, or
+// the buffered output is copied to the clipboard (printed instead if that fails).
+func (s *outputSink) Close() error {
+	if err := s.Flush(); err != nil {
+		if s.file != nil {
+			s.file.Close()
+		}
+		return err
+	}
+	switch {
+	case s.file != nil:
+		if err := s.file.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(logOut, "Output saved to %s\n", outputFile)
+	case s.clipboard != nil:
+		if err := clipboard.WriteAll(s.clipboard.String()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing to clipboard: %v\n", err)
+			fmt.Println("\n--- Output (clipboard failed) ---")
+			fmt.Println(s.clipboard.String())
+		} else {
+			fmt.Fprintln(logOut, "Output copied to clipboard.")
+		}
+	}
+	return nil
+}
+
+// Abort discards the output after a rendering error: nothing is copied to the
+// clipboard, and a partially written --file is removed.
+func (s *outputSink) Abort() {
+	s.Reset(io.Discard)
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcessInOrder(t *testing.T) {
+	for _, tt := range []struct{ n, workers, window int }{
+		{0, 4, 8},
+		{1, 1, 1},
+		{50, 1, 1},
+		{50, 4, 8},
+		{200, 8, 3},
+	} {
+		var mu sync.Mutex
+		inFlight, maxInFlight := 0, 0
+		var order []int
+		err := processInOrder(tt.n, tt.workers, tt.window, func(i int) processResult {
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mu.Unlock()
+			time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond) // Finish out of order
+			return processResult{file: FileInfo{Size: int64(i)}}
+		}, func(i int, result processResult) error {
+			if result.file.Size != int64(i) {
+				t.Errorf("emit(%d) got the result of %d", i, result.file.Size)
+			}
+			order = append(order, i)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(order) != tt.n {
+			t.Errorf("n=%d: emitted %d results", tt.n, len(order))
+		}
+		for i, got := range order {
+			if got != i {
+				t.Fatalf("n=%d: result %d emitted at position %d", tt.n, got, i)
+			}
+		}
+		if maxInFlight > tt.window {
+			t.Errorf("n=%d workers=%d window=%d: %d results in flight", tt.n, tt.workers, tt.window, maxInFlight)
+		}
+	}
+}
+
+func TestProcessInOrderStopsOnError(t *testing.T) {
+	failure := errors.New("disk full")
+	var mu sync.Mutex
+	worked := 0
+	err := processInOrder(1000, 4, 8, func(i int) processResult {
+		mu.Lock()
+		worked++
+		mu.Unlock()
+		return processResult{}
+	}, func(i int, result processResult) error {
+		if i == 10 {
+			return failure
+		}
+		return nil
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("processInOrder() = %v, want %v", err, failure)
+	}
+	time.Sleep(10 * time.Millisecond) // Let abandoned workers finish
+	mu.Lock()
+	defer mu.Unlock()
+	if worked > 10+1+8 {
+		t.Errorf("%d files worked on after emit failed at 10", worked)
+	}
+}
+
+func TestContentStore(t *testing.T) {
+	store, err := newContentStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spill := store.file.Name()
+
+	var files []FileInfo
+	for _, content := range []string{"first\n", "", "third file\n"} {
+		stored, err := store.add([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, FileInfo{Emitted: stored})
+	}
+	for i, want := range []string{"first\n", "", "third file\n"} {
+		got, err := store.content(files[i])
+		if err != nil || string(got) != want {
+			t.Errorf("content(%d) = %q, %v; want %q", i, got, err, want)
+		}
+	}
+	// Out of order and repeated reads return the same bytes
+	if got, _ := store.content(files[0]); string(got) != "first\n" {
+		t.Errorf("repeated content(0) = %q", got)
+	}
+
+	readErr := errors.New("permission denied")
+	if _, err := store.content(FileInfo{Error: readErr}); !errors.Is(err, readErr) {
+		t.Errorf("content() of a failed file = %v, want %v", err, readErr)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(spill); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("spill file %s left behind: %v", spill, err)
+	}
+}
+
+func TestProcessFilesReadsEachFileOnce(t *testing.T) {
+	dir := t.TempDir()
+	var files []FileInfo
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		writeTestFile(t, filepath.Join(dir, name), "content of "+name+"\n")
+		source, sourcePath := fileSource(filepath.Join(dir, name))
+		files = append(files, FileInfo{Path: name, Source: source, SourcePath: sourcePath})
+	}
+	files = append(files, FileInfo{Path: "missing.txt", Source: os.DirFS(dir), SourcePath: "missing.txt"})
+
+	processed, store, err := processFiles(files, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// Changing the files after processing doesn't change what is written
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		writeTestFile(t, filepath.Join(dir, name), "changed\n")
+	}
+	for i, file := range processed[:3] {
+		got, err := store.content(file)
+		if want := "content of " + files[i].Path + "\n"; err != nil || string(got) != want {
+			t.Errorf("content(%s) = %q, %v; want %q", file.Path, got, err, want)
+		}
+	}
+	if processed[3].Error == nil {
+		t.Error("missing file processed without an error")
+	}
+}
+
+func TestOutputSinkClose(t *testing.T) {
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	setFlag(t, &copyToClipboard, false)
+	path := filepath.Join(t.TempDir(), "out.txt")
+	setFlag(t, &outputFile, path)
+
+	sink, err := openOutputSink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.WriteString("rendered output\n")
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "rendered output\n" {
+		t.Errorf("output file = %q, %v", content, err)
+	}
+}
+
+func TestOutputSinkAbort(t *testing.T) {
+	setFlag(t, &copyToClipboard, false)
+	path := filepath.Join(t.TempDir(), "out.txt")
+	setFlag(t, &outputFile, path)
+
+	sink, err := openOutputSink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.WriteString("partial output")
+	sink.Flush()
+	sink.Abort()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("aborted output file left behind: %v", err)
+	}
+}
+
+func TestOpenOutputSinkError(t *testing.T) {
+	setFlag(t, &copyToClipboard, false)
+	setFlag(t, &outputFile, filepath.Join(t.TempDir(), "missing", "out.txt"))
+	if _, err := openOutputSink(); err == nil {
+		t.Error("openOutputSink() into a missing directory: expected an error")
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	gitignore "github.com/monochromegane/go-gitignore"
 )
 
 // processLocalPath handles a single local file or directory path.
@@ -20,17 +18,26 @@ func processLocalPath(path string, langData *LoadedLanguageData) ([]FileInfo, er
 
 	var files []FileInfo
 
+	// --changed/--since: only files touched in the repository are kept
+	var changes *changeSet
+	if changeSelectionEnabled() {
+		changes, err = loadChangeSet(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if info.IsDir() {
 		// It's a directory, start walking
-		fmt.Printf("Processing directory: %s\n", path) // Placeholder
+		fmt.Fprintf(logOut, "Processing directory: %s\n", path) // Placeholder
 		// Pass langData to walkDirectory
-		files, err = walkDirectory(path, langData)
+		files, err = walkDirectory(path, langData, changes)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		// It's a single file
-		fmt.Printf("Processing file: %s\n", path) // Placeholder
+		fmt.Fprintf(logOut, "Processing file: %s\n", path) // Placeholder
 		// Apply filters even for single files, passing langData
 		keep, err := shouldKeepFile(path, info, langData)
 		if err != nil {
@@ -38,14 +45,29 @@ func processLocalPath(path string, langData *LoadedLanguageData) ([]FileInfo, er
 			// Decide if we should error out or just skip
 		} else if keep {
 			fileInfo := FileInfo{
-				Path:  path,
-				Size:  info.Size(),
-				Mode:  info.Mode(),
-				IsDir: false,
+				Path:    path,
+				Size:    info.Size(),
+				Mode:    info.Mode(),
+				ModTime: info.ModTime(),
+				IsDir:   false,
+			}
+			fileInfo.Source, fileInfo.SourcePath = fileSource(path)
+			if changes != nil {
+				status, changed := changes.status(path)
+				if !changed {
+					fmt.Fprintf(logOut, "Skipping unchanged file: %s\n", path)
+					return files, nil
+				}
+				fileInfo.ChangeStatus = status
+				if diffEnabled() {
+					if fileInfo.Diff, err = changes.diffInputFor(path); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+					}
+				}
 			}
 			files = append(files, fileInfo)
 		} else {
-			fmt.Printf("Skipping single file due to filters: %s\n", path)
+			fmt.Fprintf(logOut, "Skipping single file due to filters: %s\n", path)
 		}
 	}
 
@@ -57,50 +79,41 @@ func parsePatterns(patterns string) []string {
 	if patterns == "" {
 		return nil
 	}
-	return strings.Split(patterns, ",")
-}
-
-// matchesAnyPattern checks if the given name matches any of the provided glob patterns.
-func matchesAnyPattern(name string, patterns []string) (bool, error) {
-	for _, pattern := range patterns {
-		matched, err := filepath.Match(pattern, name)
-		if err != nil {
-			return false, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
-		}
-		if matched {
-			return true, nil
+	var result []string
+	for _, pattern := range strings.Split(patterns, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			result = append(result, pattern)
 		}
 	}
-	return false, nil
+	return result
 }
 
-// walkDirectory recursively walks a directory, respecting filters and .gitignore.
-// It now accepts LoadedLanguageData for filtering.
-func walkDirectory(root string, langData *LoadedLanguageData) ([]FileInfo, error) {
+// walkDirectory recursively walks a local directory, respecting filters and .gitignore.
+// It now accepts LoadedLanguageData for filtering. When changes is non-nil only
+// changed files are kept, and deleted files are added without content.
+func walkDirectory(root string, langData *LoadedLanguageData, changes *changeSet) ([]FileInfo, error) {
+	return walkSource(dirSource(root), langData, changes)
+}
+
+// walkSource walks the file system of an input source, applying the same filters
+// to directories, clones and archives. Git-backed features (submodules, change
+// selection) only apply to sources on disk.
+func walkSource(src inputSource, langData *LoadedLanguageData, changes *changeSet) ([]FileInfo, error) {
 	var files []FileInfo
-	var ignoreMatcher gitignore.IgnoreMatcher
-
-	parsedIncludes := parsePatterns(includePatterns)
-	parsedExcludes := parsePatterns(excludePatterns)
-	// Check if explicit includes were provided. If not, language filtering might apply.
-	hasExplicitIncludes := len(parsedIncludes) > 0
-
-	if !noIgnore {
-		// TODO: Consider handling nested .gitignore files?
-		// go-gitignore primarily works with one .gitignore at the root level of the match.
-		// For full git compatibility, might need a more complex walker or library.
-		gitIgnorePath := filepath.Join(root, ".gitignore")
-		if _, err := os.Stat(gitIgnorePath); err == nil {
-			matcher, err := gitignore.NewGitIgnore(gitIgnorePath)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: could not parse .gitignore file %s: %v\n", gitIgnorePath, err)
-			} else {
-				ignoreMatcher = matcher
-			}
-		}
+	root := src.root
+	filter := newEntryFilter(langData)
+
+	// Nested ignore files are picked up as their directories are visited.
+	// With --no-ignore only .irisignore files are honored.
+	ignoreMatcher := src.ignoreRules(!noIgnore)
+	// Submodules are recognised by their gitlink entries in the repository index
+	submodules := &submoduleIndex{}
+	if src.onDisk {
+		submodules = newSubmoduleIndex(root)
 	}
 
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	err := fs.WalkDir(src.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		path := src.displayPath(name)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: error accessing path %s: %v\n", path, err)
 			// Optionally return err to stop walk, or fs.SkipDir for directory errors?
@@ -108,7 +121,7 @@ func walkDirectory(root string, langData *LoadedLanguageData) ([]FileInfo, error
 		}
 
 		// Skip root directory itself
-		if path == root {
+		if name == "." {
 			return nil
 		}
 
@@ -116,109 +129,118 @@ func walkDirectory(root string, langData *LoadedLanguageData) ([]FileInfo, error
 		baseName := d.Name()
 		isDir := d.IsDir()
 
-		// 1. Hidden Files/Dirs
-		if !showHidden && isHidden(baseName) {
+		// Git metadata is never content, even with --hidden. It is a file in
+		// linked worktrees and submodule checkouts.
+		if baseName == ".git" {
 			if isDir {
 				return fs.SkipDir
 			}
 			return nil
 		}
 
-		// 2. .gitignore
-		// Need the path relative to the gitignore file (usually the root)
-		relPathForIgnore, _ := filepath.Rel(root, path)
-		if ignoreMatcher != nil && ignoreMatcher.Match(relPathForIgnore, isDir) {
+		// 1. Hidden Files/Dirs
+		if filter.hidden(baseName) {
 			if isDir {
 				return fs.SkipDir
 			}
 			return nil
 		}
 
-		// 3. Max Depth
-		relPath, _ := filepath.Rel(root, path)
-		currentDepth := countPathSeparators(relPath)
-		if maxDepth > 0 && currentDepth >= maxDepth {
+		// 2. .gitignore (nested files, .git/info/exclude and global excludes)
+		// plus .irisignore files, which apply even with --no-ignore
+		if ignoreMatcher.Match(path, isDir) {
 			if isDir {
-				return fs.SkipDir // Reached max depth, skip this directory
+				recordSkip(path+string(filepath.Separator), "ignored")
+				return fs.SkipDir
 			}
-			// If it's a file at max depth, it might still be processed below
+			recordSkip(path, "ignored")
+			return nil
 		}
 
-		// Apply Include/Exclude/Language Filters
-		// If it's a directory, we check excludes but not includes/language yet (allow traversal)
+		relPath := name // Patterns are matched against slash-separated paths relative to root
+
 		if isDir {
-			// 4a. Exclude Pattern Match (Directories)
-			excluded, err := matchesAnyPattern(baseName, parsedExcludes)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: error in exclude pattern matching for %s: %v\n", path, err)
-				// Decide how to handle pattern errors - skip file or ignore pattern?
-			}
-			if excluded {
-				return fs.SkipDir // Skip excluded directories
-			}
-			// Allow traversal of non-excluded directories
-		} else {
-			// Apply full filters to files
-			fileName := baseName
-
-			// 4a. Exclude Pattern Match (Files)
-			excluded, err := matchesAnyPattern(fileName, parsedExcludes)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: error in exclude pattern matching for %s: %v\n", path, err)
-				// Decide how to handle pattern errors - skip file or ignore pattern?
+			// 3. Vendored, max depth and exclude patterns prune the whole subtree
+			if reason, skip := filter.skipDir(baseName, relPath); skip {
+				if reason != "" {
+					recordSkip(path+string(filepath.Separator), reason)
+				}
+				return fs.SkipDir
 			}
-			if excluded {
-				return nil // Skip excluded files
+			ignoreMatcher.loadDir(path) // Rules in this directory apply to everything below it
+			if changes != nil && !changes.containsDir(path) {
+				return fs.SkipDir // Nothing changed below this directory
 			}
-
-			// 4b. Include Pattern Match OR Language Match (Files)
-			keepFile := false
-			if hasExplicitIncludes {
-				// If includes are specified, use them
-				included, err := matchesAnyPattern(fileName, parsedIncludes)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: error in include pattern matching for %s: %v\n", path, err)
+			// 4. Submodules are listed with their pinned commit and only walked
+			// when --submodules selects them
+			if commit, level, ok := submodules.lookup(path); ok {
+				entry := FileInfo{Path: path, IsDir: true, Submodule: commit}
+				if info, err := d.Info(); err == nil {
+					entry.Mode = info.Mode()
+					entry.ModTime = info.ModTime()
 				}
-				if included {
-					keepFile = true
+				switch {
+				case !submoduleSelected(level):
+					entry.SkipReason = submoduleSkipReason
+				case !isCheckedOut(path):
+					entry.SkipReason = submoduleUninitializedReason
 				}
-			} else if langData != nil {
-				// If no includes specified AND langData exists, check language
-				if _, knownLang := langData.GetLanguageForFile(path); knownLang {
-					keepFile = true
+				files = append(files, entry)
+				if entry.SkipReason != "" {
+					recordSkip(path+string(filepath.Separator), submoduleSkipReason+" ("+entry.SkipReason+")")
+					return fs.SkipDir
 				}
-			} else {
-				// No includes, no langData -> keep all non-excluded files
-				keepFile = true
+				submodules.enter(path, level)
 			}
+			return nil // Allow traversal of non-excluded directories
+		}
 
-			if !keepFile {
-				return nil // Skip files not matching includes or known languages (if applicable)
+		// 3. Change selection (--changed/--since)
+		var changeStatus string
+		if changes != nil {
+			status, changed := changes.status(path)
+			if !changed {
+				return nil
 			}
+			changeStatus = status
+		}
 
-			// 5. Max Size (apply only to files)
-			var fileSize int64
-			var fileMode fs.FileMode
-			info, err := d.Info()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: could not get info for %s: %v\n", path, err)
-				return nil // Skip file if info error
-			}
-			fileSize = info.Size()
-			fileMode = info.Mode()
-			if maxSizeBytes > 0 && fileSize > maxSizeBytes {
-				return nil // Skip large files
+		// 4. Exclude, include and language filters
+		if reason, skip := filter.skipFile(path, relPath); skip {
+			if reason != "" {
+				recordSkip(path, reason)
 			}
+			return nil
+		}
 
-			// If file passes all filters, add it
-			fileInfo := FileInfo{
-				Path:  path,
-				Size:  fileSize,
-				Mode:  fileMode,
-				IsDir: false,
+		// 5. Max Size
+		info, err := d.Info()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not get info for %s: %v\n", path, err)
+			return nil // Skip file if info error
+		}
+		if reason, skip := filter.skipSize(info.Size()); skip {
+			recordSkip(path, reason)
+			return nil
+		}
+
+		// If file passes all filters, add it
+		fileInfo := FileInfo{
+			Path:         path,
+			Source:       src.fsys,
+			SourcePath:   name,
+			Size:         info.Size(),
+			Mode:         info.Mode(),
+			ModTime:      info.ModTime(),
+			IsDir:        false,
+			ChangeStatus: changeStatus,
+		}
+		if changes != nil && diffEnabled() {
+			if fileInfo.Diff, err = changes.diffInputFor(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 			}
-			files = append(files, fileInfo)
 		}
+		files = append(files, fileInfo)
 		// --- End Filtering Logic ---
 
 		return nil
@@ -228,63 +250,75 @@ func walkDirectory(root string, langData *LoadedLanguageData) ([]FileInfo, error
 		return nil, fmt.Errorf("error walking directory %s: %w", root, err)
 	}
 
+	// Deleted files no longer exist to be walked, so they are matched against the
+	// path-based filters here and listed without content (or as a diff with --diff).
+	if changes != nil {
+		for _, path := range changes.deletedUnder(root) {
+			relPath, _ := filepath.Rel(root, path)
+			relPath = filepath.ToSlash(relPath)
+			if !showHidden && hasHiddenComponent(relPath) {
+				continue
+			}
+			if _, skip := filter.skipFile(path, relPath); skip {
+				continue
+			}
+			deleted := FileInfo{Path: path, ChangeStatus: "D", SkipReason: deletedSkipReason}
+			if diffEnabled() {
+				// With --diff a deletion is a diff like any other; empty content stands in for the missing file
+				diff, err := changes.diffInputFor(path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				} else {
+					deleted.SkipReason = ""
+					deleted.Source, deleted.SourcePath = contentSource(path, nil)
+					deleted.Diff = diff
+				}
+			}
+			files = append(files, deleted)
+		}
+	}
+
 	return files, nil
 }
 
 // shouldKeepFile checks if a single file (not in a walk) should be kept based on filters.
 // It now accepts LoadedLanguageData for filtering.
 func shouldKeepFile(path string, info fs.FileInfo, langData *LoadedLanguageData) (bool, error) {
-	baseName := info.Name()
+	filter := newEntryFilter(langData)
 
 	// Hidden
-	if !showHidden && isHidden(baseName) {
+	if filter.hidden(info.Name()) {
 		return false, nil
 	}
 
-	// Gitignore - less relevant for single file args unless we load a relevant .gitignore?
-	// Glimpse probably doesn't apply gitignore to explicit file args.
-
-	// Include/Exclude/Language
-	parsedIncludes := parsePatterns(includePatterns)
-	parsedExcludes := parsePatterns(excludePatterns)
-	hasExplicitIncludes := len(parsedIncludes) > 0
-
-	excluded, err := matchesAnyPattern(baseName, parsedExcludes)
-	if err != nil {
-		return false, fmt.Errorf("exclude pattern error: %w", err)
-	}
-	if excluded {
+	// Gitignore/.irisignore - the file's own directory and its ancestors up to the repository root
+	if newIgnoreRules(filepath.Dir(path), !noIgnore).Match(path, false) {
 		return false, nil
 	}
 
-	keepFile := false
-	if hasExplicitIncludes {
-		included, err := matchesAnyPattern(baseName, parsedIncludes)
-		if err != nil {
-			return false, fmt.Errorf("include pattern error: %w", err)
-		}
-		if included {
-			keepFile = true
-		}
-	} else if langData != nil {
-		if _, knownLang := langData.GetLanguageForFile(path); knownLang {
-			keepFile = true
-		}
-	} else {
-		keepFile = true // Keep if not excluded and no includes/lang specified
-	}
-	if !keepFile {
+	// Include/Exclude/Language - single file arguments are matched by the path as given (slash-separated)
+	if _, skip := filter.skipFile(path, filepath.ToSlash(filepath.Clean(path))); skip {
 		return false, nil
 	}
 
 	// Max Size
-	if maxSizeBytes > 0 && info.Size() > maxSizeBytes {
+	if _, skip := filter.skipSize(info.Size()); skip {
 		return false, nil
 	}
 
 	return true, nil
 }
 
+// hasHiddenComponent reports whether any element of a slash-separated relative path is hidden.
+func hasHiddenComponent(relPath string) bool {
+	for _, part := range strings.Split(relPath, "/") {
+		if isHidden(part) {
+			return true
+		}
+	}
+	return false
+}
+
 // isHidden checks if a file path is hidden (starts with '.').
 func isHidden(path string) bool {
 	// Check for '.' or '..'
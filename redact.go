@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// secretRule detects one kind of credential. When Pattern has a capture group only
+// the first group is treated as the secret (so `KEY=` prefixes survive redaction),
+// otherwise the whole match is. MinEntropy, when set, drops matches whose secret
+// part looks too regular to be a credential.
+type secretRule struct {
+	Name       string
+	Pattern    *regexp.Regexp
+	MinEntropy float64
+}
+
+// configSecretRule is the shape of a `[[redact_rules]]` entry in config.toml.
+type configSecretRule struct {
+	Name       string  `mapstructure:"name"`
+	Pattern    string  `mapstructure:"pattern"`
+	MinEntropy float64 `mapstructure:"min_entropy"`
+}
+
+// defaultSecretRules are always active when scanning. Private keys come first so
+// their multi-line blocks are redacted whole before other rules see them.
+var defaultSecretRules = []secretRule{
+	{Name: "private-key", Pattern: regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----.*?-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----`)},
+	{Name: "aws-access-key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{Name: "aws-secret-key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`)},
+	{Name: "github-token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{Name: "slack-token", Pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+}
+
+// envSecretRule catches high-entropy values in .env-style `KEY=value` lines. It runs
+// after the specific and configured rules so they get to name what they recognise.
+var envSecretRule = secretRule{
+	Name:       "env-secret",
+	Pattern:    regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?[A-Za-z_][A-Za-z0-9_]*=["']?([A-Za-z0-9+/=_.~-]{16,})["']?[ \t]*$`),
+	MinEntropy: 3.5,
+}
+
+// secretRules is the active rule set, built by loadSecretRules when scanning is enabled.
+var secretRules []secretRule
+
+// secretScanEnabled reports whether --redact or --fail-on-secrets asked for scanning.
+func secretScanEnabled() bool {
+	return redactSecrets || failOnSecrets
+}
+
+// loadSecretRules returns the built-in rules and any `redact_rules` from config, in the
+// order they are applied.
+func loadSecretRules() ([]secretRule, error) {
+	rules := append([]secretRule(nil), defaultSecretRules...)
+
+	var configured []configSecretRule
+	if err := viper.UnmarshalKey("redact_rules", &configured); err != nil {
+		return nil, fmt.Errorf("invalid redact_rules in config: %w", err)
+	}
+	for i, rule := range configured {
+		if rule.Name == "" || rule.Pattern == "" {
+			return nil, fmt.Errorf("redact_rules entry %d needs both name and pattern", i+1)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact_rules entry %q: %w", rule.Name, err)
+		}
+		rules = append(rules, secretRule{Name: rule.Name, Pattern: pattern, MinEntropy: rule.MinEntropy})
+	}
+	return append(rules, envSecretRule), nil
+}
+
+// scanSecrets finds secrets in content using secretRules. It returns the content
+// with each secret replaced by [REDACTED:<rule>] (when redact is true, otherwise
+// the content is returned unchanged) and the number of matches per rule.
+// Each rule sees the output of the previous ones, so a secret matched by two
+// rules is counted once.
+func scanSecrets(content []byte, redact bool) ([]byte, map[string]int) {
+	var found map[string]int
+	redacted := content
+	for _, rule := range secretRules {
+		matches := rule.Pattern.FindAllSubmatchIndex(redacted, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var out []byte
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3] // Only the first capture group is secret
+			}
+			if rule.MinEntropy > 0 && shannonEntropy(redacted[start:end]) < rule.MinEntropy {
+				continue
+			}
+			if found == nil {
+				found = make(map[string]int)
+			}
+			found[rule.Name]++
+			out = append(out, redacted[last:start]...)
+			out = append(out, "[REDACTED:"+rule.Name+"]"...)
+			last = end
+		}
+		if out != nil {
+			redacted = append(out, redacted[last:]...)
+		}
+	}
+	if !redact {
+		return content, found
+	}
+	return redacted, found
+}
+
+// shannonEntropy returns the entropy of s in bits per byte.
+func shannonEntropy(s []byte) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range s {
+		counts[b]++
+	}
+	var entropy float64
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// formatSecretCounts renders per-rule counts as "jwt: 2, private-key: 1".
+func formatSecretCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %d", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// countSecrets returns the total number of secrets in counts.
+func countSecrets(counts map[string]int) int {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
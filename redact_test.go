@@ -0,0 +1,138 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// useSecretRules activates the default rules plus extra for the duration of a test.
+func useSecretRules(t *testing.T, extra ...configSecretRule) {
+	t.Helper()
+	old := secretRules
+	t.Cleanup(func() { secretRules = old })
+
+	v := viper.GetViper()
+	oldConfigured := v.Get("redact_rules")
+	t.Cleanup(func() { v.Set("redact_rules", oldConfigured) })
+	if len(extra) > 0 {
+		v.Set("redact_rules", extra)
+	} else {
+		v.Set("redact_rules", nil)
+	}
+
+	rules, err := loadSecretRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretRules = rules
+}
+
+func TestScanSecrets(t *testing.T) {
+	// Fake credentials are assembled so this file doesn't look like it leaks any
+	githubToken := "ghp_" + strings.Repeat("a1B2", 9)
+	privateKey := "-----BEGIN " + "RSA PRIVATE KEY-----\nMIIEow\nAKIA\n-----END " + "RSA PRIVATE KEY-----"
+	awsSecret := strings.Repeat("wJalrXUtnFEMI/K7MDENG/bPxRfiCY", 2)[:40]
+
+	tests := []struct {
+		name     string
+		content  string
+		redacted string
+		counts   map[string]int
+	}{
+		{
+			name:     "no secrets",
+			content:  "package main\n\nfunc main() {}\n",
+			redacted: "package main\n\nfunc main() {}\n",
+		},
+		{
+			name:     "aws access key",
+			content:  "key := \"AKIA" + "IOSFODNN7EXAMPLE\"\n",
+			redacted: "key := \"[REDACTED:aws-access-key]\"\n",
+			counts:   map[string]int{"aws-access-key": 1},
+		},
+		{
+			name:     "capture group keeps the prefix",
+			content:  "aws_secret_access_key = " + awsSecret + "\n",
+			redacted: "aws_secret_access_key = [REDACTED:aws-secret-key]\n",
+			counts:   map[string]int{"aws-secret-key": 1},
+		},
+		{
+			name:     "repeated token",
+			content:  githubToken + " " + githubToken,
+			redacted: "[REDACTED:github-token] [REDACTED:github-token]",
+			counts:   map[string]int{"github-token": 2},
+		},
+		{
+			name:     "private key block is redacted whole",
+			content:  "before\n" + privateKey + "\nafter\n",
+			redacted: "before\n[REDACTED:private-key]\nafter\n",
+			counts:   map[string]int{"private-key": 1},
+		},
+		{
+			name:     "high entropy env value",
+			content:  "API_TOKEN=\"Zq8xN3vR7kLp2Tw9Yb4M\"\nDEBUG=true\n",
+			redacted: "API_TOKEN=\"[REDACTED:env-secret]\"\nDEBUG=true\n",
+			counts:   map[string]int{"env-secret": 1},
+		},
+		{
+			name:     "low entropy env value",
+			content:  "PLACEHOLDER=aaaaaaaaaaaaaaaaaaaa\n",
+			redacted: "PLACEHOLDER=aaaaaaaaaaaaaaaaaaaa\n",
+		},
+	}
+	useSecretRules(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, counts := scanSecrets([]byte(tt.content), true)
+			if string(got) != tt.redacted {
+				t.Errorf("scanSecrets() content = %q, want %q", got, tt.redacted)
+			}
+			if !reflect.DeepEqual(counts, tt.counts) {
+				t.Errorf("scanSecrets() counts = %v, want %v", counts, tt.counts)
+			}
+
+			// Without redaction the content is untouched but secrets are still counted
+			got, counts = scanSecrets([]byte(tt.content), false)
+			if string(got) != tt.content || !reflect.DeepEqual(counts, tt.counts) {
+				t.Errorf("scanSecrets(redact=false) = %q, %v; want %q, %v", got, counts, tt.content, tt.counts)
+			}
+		})
+	}
+}
+
+func TestScanSecretsConfiguredRules(t *testing.T) {
+	useSecretRules(t, configSecretRule{Name: "internal-token", Pattern: `itk_[0-9a-f]{8}`})
+	got, counts := scanSecrets([]byte("token: itk_deadbeef\n"), true)
+	if string(got) != "token: [REDACTED:internal-token]\n" || counts["internal-token"] != 1 {
+		t.Errorf("scanSecrets() = %q, %v", got, counts)
+	}
+}
+
+func TestLoadSecretRulesInvalid(t *testing.T) {
+	v := viper.GetViper()
+	old := v.Get("redact_rules")
+	t.Cleanup(func() { v.Set("redact_rules", old) })
+
+	for _, rule := range []configSecretRule{
+		{Name: "", Pattern: "x"},
+		{Name: "broken", Pattern: "("},
+	} {
+		v.Set("redact_rules", []configSecretRule{rule})
+		if _, err := loadSecretRules(); err == nil {
+			t.Errorf("loadSecretRules() with %+v: expected an error", rule)
+		}
+	}
+}
+
+func TestFormatSecretCounts(t *testing.T) {
+	counts := map[string]int{"private-key": 1, "jwt": 2}
+	if got := formatSecretCounts(counts); got != "jwt: 2, private-key: 1" {
+		t.Errorf("formatSecretCounts() = %q", got)
+	}
+	if got := countSecrets(counts); got != 3 {
+		t.Errorf("countSecrets() = %d, want 3", got)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// skipEntry is one line of the --verbose skip report.
+type skipEntry struct {
+	Path   string
+	Reason string
+}
+
+// skipReport collects files and directories left out of the output and why.
+// Workers record into it concurrently.
+var skipReport struct {
+	mu      sync.Mutex
+	entries []skipEntry
+}
+
+// recordSkip adds path to the skip report. It is a no-op unless --verbose is set.
+func recordSkip(path, reason string) {
+	if !verbose {
+		return
+	}
+	skipReport.mu.Lock()
+	defer skipReport.mu.Unlock()
+	skipReport.entries = append(skipReport.entries, skipEntry{Path: path, Reason: reason})
+}
+
+// printSkipReport writes the collected skips, sorted by path, to w.
+func printSkipReport(w io.Writer) {
+	skipReport.mu.Lock()
+	defer skipReport.mu.Unlock()
+	if len(skipReport.entries) == 0 {
+		return
+	}
+	sort.SliceStable(skipReport.entries, func(i, j int) bool {
+		return skipReport.entries[i].Path < skipReport.entries[j].Path
+	})
+	fmt.Fprintf(w, "--- Skipped (%d) ---\n", len(skipReport.entries))
+	for _, entry := range skipReport.entries {
+		fmt.Fprintf(w, "%s: %s\n", entry.Path, entry.Reason)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// resetSkipReport empties the skip report for the duration of a test.
+func resetSkipReport(t *testing.T) {
+	t.Helper()
+	skipReport.mu.Lock()
+	old := skipReport.entries
+	skipReport.entries = nil
+	skipReport.mu.Unlock()
+	t.Cleanup(func() {
+		skipReport.mu.Lock()
+		skipReport.entries = old
+		skipReport.mu.Unlock()
+	})
+}
+
+func TestSkipReport(t *testing.T) {
+	resetSkipReport(t)
+	setFlag(t, &verbose, false)
+	recordSkip("ignored.txt", "not verbose")
+	var out strings.Builder
+	printSkipReport(&out)
+	if out.Len() != 0 {
+		t.Errorf("skips recorded without --verbose:\n%s", out.String())
+	}
+
+	setFlag(t, &verbose, true)
+	recordSkip("web/app.min.js", "generated (name matches *.min.js)")
+	recordSkip("assets/logo.png", "binary (image/png)")
+	recordSkip("vendor", "vendored")
+	out.Reset()
+	printSkipReport(&out)
+	want := "--- Skipped (3) ---\nassets/logo.png: binary (image/png)\nvendor: vendored\nweb/app.min.js: generated (name matches *.min.js)\n"
+	if out.String() != want {
+		t.Errorf("printSkipReport() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestSkipReportConcurrent(t *testing.T) {
+	resetSkipReport(t)
+	setFlag(t, &verbose, true)
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recordSkip(fmt.Sprintf("file%02d.bin", i), binarySkipReason)
+		}()
+	}
+	wg.Wait()
+
+	var out strings.Builder
+	printSkipReport(&out)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 21 || lines[0] != "--- Skipped (20) ---" || lines[1] != "file00.bin: binary" || lines[20] != "file19.bin: binary" {
+		t.Errorf("printSkipReport() =\n%s", out.String())
+	}
+}
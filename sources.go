@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// inputSource exposes one input as an io/fs file system, so local directories,
+// cloned repositories and archives are walked, filtered and read through the same
+// interface. Files are shown as root joined with their slash-separated path inside
+// fsys. Web pages and stdin have no tree to walk; each is read from a single-file
+// in-memory file system instead (see contentSource).
+type inputSource struct {
+	fsys   fs.FS
+	root   string // Path the files are shown under; also the directory behind fsys when onDisk
+	onDisk bool   // Backed by the directory root, so git metadata, change selection and submodules apply
+}
+
+// dirSource returns the source for a local directory (or a clone of a repository).
+func dirSource(dir string) inputSource {
+	return inputSource{fsys: os.DirFS(dir), root: dir, onDisk: true}
+}
+
+// fileSource returns the file system and name to read a single local file through.
+func fileSource(file string) (fs.FS, string) {
+	return os.DirFS(filepath.Dir(file)), filepath.Base(file)
+}
+
+// contentSource returns an in-memory file system holding content as its only file,
+// and that file's name, for inputs without a tree of their own (web pages, stdin).
+func contentSource(input string, content []byte) (fs.FS, string) {
+	const name = "content"
+	return newMemFS(input, []archiveFile{{path: name, mode: 0o644, content: content}}), name
+}
+
+// displayPath returns the path a file of the source is shown (and filtered) as.
+func (s inputSource) displayPath(name string) string {
+	if name == "." {
+		return s.root
+	}
+	return filepath.Join(s.root, filepath.FromSlash(name))
+}
+
+// ignoreRules returns the ignore rules for walking the source. Sources on disk
+// use git's full set of ignore sources; other sources only the ignore files
+// inside them.
+func (s inputSource) ignoreRules(useGit bool) *ignoreRules {
+	if s.onDisk {
+		return newIgnoreRules(s.root, useGit)
+	}
+	r := &ignoreRules{root: s.root, useGit: useGit, fsys: s.fsys, loaded: make(map[string]bool)}
+	r.loadDir(s.root)
+	return r
+}
+
+// memFS is a read-only, in-memory file system, used for the files of an archive
+// and for content fetched from the web or read from stdin.
+// Directories are implied by the files inside them.
+type memFS struct {
+	entries map[string]*memEntry     // Slash-separated path -> file or directory, "." for the root
+	dirs    map[string][]fs.DirEntry // Directory path -> its entries, sorted by name
+}
+
+// memEntry is a file or directory of a memFS. It is its own fs.FileInfo.
+type memEntry struct {
+	name    string // Base name
+	mode    fs.FileMode
+	modTime time.Time
+	content []byte
+}
+
+func (e *memEntry) Name() string       { return e.name }
+func (e *memEntry) Size() int64        { return int64(len(e.content)) }
+func (e *memEntry) Mode() fs.FileMode  { return e.mode }
+func (e *memEntry) ModTime() time.Time { return e.modTime }
+func (e *memEntry) IsDir() bool        { return e.mode.IsDir() }
+func (e *memEntry) Sys() any           { return nil }
+
+// newMemFS builds a file system from archive files. Later files replace earlier
+// ones with the same path, as extracting the archive would. A file whose path is
+// also a directory of other files is dropped with a warning.
+func newMemFS(input string, files []archiveFile) *memFS {
+	m := &memFS{
+		entries: map[string]*memEntry{".": {name: ".", mode: fs.ModeDir | 0o755}},
+		dirs:    make(map[string][]fs.DirEntry),
+	}
+	for _, file := range files {
+		m.entries[file.path] = &memEntry{name: path.Base(file.path), mode: file.mode, modTime: file.modTime, content: file.content}
+	}
+	for _, file := range files {
+		for dir := path.Dir(file.path); dir != "."; dir = path.Dir(dir) {
+			if existing, ok := m.entries[dir]; ok && !existing.IsDir() {
+				fmt.Fprintf(os.Stderr, "Warning: skipping %s in archive %s, it is also a directory\n", dir, input)
+			}
+			m.entries[dir] = &memEntry{name: path.Base(dir), mode: fs.ModeDir | 0o755}
+		}
+	}
+
+	children := make(map[string]map[string]bool)
+	for name := range m.entries {
+		if name == "." {
+			continue
+		}
+		parent := path.Dir(name)
+		if children[parent] == nil {
+			children[parent] = make(map[string]bool)
+		}
+		children[parent][name] = true
+	}
+	for dir, names := range children {
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			m.dirs[dir] = append(m.dirs[dir], fs.FileInfoToDirEntry(m.entries[name]))
+		}
+	}
+	return m
+}
+
+// Open implements fs.FS.
+func (m *memFS) Open(name string) (fs.File, error) {
+	entry, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.IsDir() {
+		return &memDir{entry: entry, entries: m.dirs[name]}, nil
+	}
+	return &memFile{entry: entry, Reader: bytes.NewReader(entry.content)}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := m.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return append([]fs.DirEntry(nil), m.dirs[name]...), nil
+}
+
+// ReadFile implements fs.ReadFileFS. Callers may modify the returned slice, so
+// it is a copy.
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	entry, err := m.lookup("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return bytes.Clone(entry.content), nil
+}
+
+// lookup returns the entry at name, or a *fs.PathError for op.
+func (m *memFS) lookup(op, name string) (*memEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := m.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, nil
+}
+
+// memFile is an open regular file of a memFS.
+type memFile struct {
+	entry *memEntry
+	*bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *memFile) Close() error               { return nil }
+
+// memDir is an open directory of a memFS.
+type memDir struct {
+	entry   *memEntry
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.entry, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	return append([]fs.DirEntry(nil), rest...), nil
+}
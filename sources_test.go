@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// walkedPaths returns the slash-separated paths walkSource lists for src.
+func walkedPaths(t *testing.T, src inputSource) []string {
+	t.Helper()
+	files, err := walkSource(src, nil, nil)
+	if err != nil {
+		t.Fatalf("walkSource() error: %v", err)
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, filepath.ToSlash(file.Path))
+	}
+	return paths
+}
+
+func TestWalkSource(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":            {Data: []byte("*.log\n")},
+		".irisignore":           {Data: []byte("docs/draft.md\n")},
+		".env":                  {Data: []byte("KEY=value\n")},
+		".git/config":           {Data: []byte("[core]\n")},
+		"main.go":               {Data: []byte("package main\n")},
+		"debug.log":             {Data: []byte("log\n")},
+		"big.txt":               {Data: make([]byte, 100)},
+		"docs/guide.md":         {Data: []byte("# Guide\n")},
+		"docs/draft.md":         {Data: []byte("# Draft\n")},
+		"internal/a/b/deep.go":  {Data: []byte("package b\n")},
+		"vendor/lib/lib.go":     {Data: []byte("package lib\n")},
+		"web/node_modules/x.js": {Data: []byte("x\n")},
+		"web/app.js":            {Data: []byte("app\n")},
+	}
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T)
+		want  []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"repo/big.txt", "repo/docs/guide.md", "repo/internal/a/b/deep.go", "repo/main.go",
+				"repo/vendor/lib/lib.go", "repo/web/app.js", "repo/web/node_modules/x.js"},
+		},
+		{
+			name:  "hidden files but never .git",
+			setup: func(t *testing.T) { setFlag(t, &showHidden, true) },
+			want: []string{"repo/.env", "repo/.gitignore", "repo/.irisignore", "repo/big.txt", "repo/docs/guide.md",
+				"repo/internal/a/b/deep.go", "repo/main.go", "repo/vendor/lib/lib.go", "repo/web/app.js", "repo/web/node_modules/x.js"},
+		},
+		{
+			name:  "no-ignore keeps .irisignore",
+			setup: func(t *testing.T) { setFlag(t, &noIgnore, true) },
+			want: []string{"repo/big.txt", "repo/debug.log", "repo/docs/guide.md", "repo/internal/a/b/deep.go",
+				"repo/main.go", "repo/vendor/lib/lib.go", "repo/web/app.js", "repo/web/node_modules/x.js"},
+		},
+		{
+			name:  "include patterns",
+			setup: func(t *testing.T) { setFlag(t, &includePatterns, "*.go,docs/*.md") },
+			want:  []string{"repo/docs/guide.md", "repo/internal/a/b/deep.go", "repo/main.go", "repo/vendor/lib/lib.go"},
+		},
+		{
+			name:  "exclude patterns prune directories",
+			setup: func(t *testing.T) { setFlag(t, &excludePatterns, "**/node_modules/**,internal/**,*.txt") },
+			want:  []string{"repo/docs/guide.md", "repo/main.go", "repo/vendor/lib/lib.go", "repo/web/app.js"},
+		},
+		{
+			name:  "max depth",
+			setup: func(t *testing.T) { setFlag(t, &maxDepth, 1) },
+			want:  []string{"repo/big.txt", "repo/docs/guide.md", "repo/main.go", "repo/web/app.js"},
+		},
+		{
+			name:  "skip vendored",
+			setup: func(t *testing.T) { setFlag(t, &skipVendored, true) },
+			want: []string{"repo/big.txt", "repo/docs/guide.md", "repo/internal/a/b/deep.go", "repo/main.go",
+				"repo/web/app.js", "repo/web/node_modules/x.js"},
+		},
+		{
+			name:  "max size",
+			setup: func(t *testing.T) { setFlag(t, &maxSizeBytes, int64(50)) },
+			want: []string{"repo/docs/guide.md", "repo/internal/a/b/deep.go", "repo/main.go",
+				"repo/vendor/lib/lib.go", "repo/web/app.js", "repo/web/node_modules/x.js"},
+		},
+		{
+			name: "max size with truncation keeps large files",
+			setup: func(t *testing.T) {
+				setFlag(t, &maxSizeBytes, int64(50))
+				setFlag(t, &truncateLines, 10)
+			},
+			want: []string{"repo/big.txt", "repo/docs/guide.md", "repo/internal/a/b/deep.go", "repo/main.go",
+				"repo/vendor/lib/lib.go", "repo/web/app.js", "repo/web/node_modules/x.js"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			got := walkedPaths(t, inputSource{fsys: fsys, root: "repo"})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("walkSource() = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalkSourceReadsThroughSource(t *testing.T) {
+	fsys := fstest.MapFS{"pkg/util.go": {Data: []byte("package pkg\n")}}
+	files, err := walkSource(inputSource{fsys: fsys, root: "archive.zip"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("walkSource() = %+v, want one file", files)
+	}
+	file := files[0]
+	if file.Path != filepath.Join("archive.zip", "pkg", "util.go") || file.SourcePath != "pkg/util.go" || file.Size != 12 {
+		t.Errorf("walkSource() file = %+v", file)
+	}
+	content, err := file.ReadContent()
+	if err != nil || string(content) != "package pkg\n" {
+		t.Errorf("ReadContent() = %q, %v", content, err)
+	}
+}
+
+func TestMemFS(t *testing.T) {
+	fsys := newMemFS("test.zip", []archiveFile{
+		{path: "README.md", mode: 0o644, content: []byte("# Readme\n")},
+		{path: "src/main.go", mode: 0o644, content: []byte("package main\n")},
+		{path: "src/util/util.go", mode: 0o644, content: []byte("package util\n")},
+	})
+	if err := fstest.TestFS(fsys, "README.md", "src/main.go", "src/util/util.go"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContentSource(t *testing.T) {
+	fsys, name := contentSource("https://example.com/page", []byte("page content"))
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil || string(content) != "page content" {
+		t.Errorf("fs.ReadFile(contentSource()) = %q, %v", content, err)
+	}
+
+	// A missing source reads as a missing file rather than empty content
+	if _, err := (FileInfo{Path: "gone.txt"}).ReadContent(); err == nil {
+		t.Error("ReadContent() without a source: expected an error")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+)
+
+// validSubmoduleModes lists the values accepted by --submodules.
+var validSubmoduleModes = []string{"skip", "include", "recurse"}
+
+// submoduleSkipReason marks submodules left out by --submodules (skip, or nested with include).
+const submoduleSkipReason = "submodule"
+
+// submoduleUninitializedReason marks submodules that were selected but have no checkout.
+const submoduleUninitializedReason = "not initialized"
+
+// submoduleIndex records the commit each submodule is pinned to, read from the
+// index of every repository the walk enters.
+type submoduleIndex struct {
+	pinned map[string]string // Absolute submodule directory -> pinned commit SHA
+	level  map[string]int    // Nesting level, 1 for submodules of the walked repository
+	loaded map[string]bool   // Repository roots whose index was read
+}
+
+// newSubmoduleIndex reads the submodules of the repository containing root.
+func newSubmoduleIndex(root string) *submoduleIndex {
+	s := &submoduleIndex{pinned: make(map[string]string), level: make(map[string]int), loaded: make(map[string]bool)}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return s
+	}
+	if repoRoot, ok := findGitRoot(absRoot); ok {
+		s.load(repoRoot, 1)
+	}
+	return s
+}
+
+// load adds the submodules (gitlink index entries) of the repository at repoRoot.
+func (s *submoduleIndex) load(repoRoot string, level int) {
+	if s.loaded[repoRoot] {
+		return
+	}
+	s.loaded[repoRoot] = true
+	repo, err := git.PlainOpenWithOptions(repoRoot, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return
+	}
+	for _, entry := range idx.Entries {
+		if entry.Mode == filemode.Submodule {
+			path := filepath.Join(repoRoot, filepath.FromSlash(entry.Name))
+			s.pinned[path] = entry.Hash.String()
+			s.level[path] = level
+		}
+	}
+}
+
+// lookup returns the pinned commit and nesting level of the submodule at dir, if it is one.
+func (s *submoduleIndex) lookup(dir string) (string, int, bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", 0, false
+	}
+	commit, ok := s.pinned[abs]
+	return commit, s.level[abs], ok
+}
+
+// enter makes the submodules nested in the checked out submodule at dir known to the walk.
+func (s *submoduleIndex) enter(dir string, level int) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		s.load(abs, level+1)
+	}
+}
+
+// submoduleSelected reports whether --submodules walks into a submodule at the given nesting level.
+func submoduleSelected(level int) bool {
+	switch submoduleMode {
+	case "recurse":
+		return true
+	case "include":
+		return level <= 1
+	}
+	return false
+}
+
+// isCheckedOut reports whether dir holds a checkout (it has a .git file or directory).
+func isCheckedOut(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// initClonedSubmodules clones the submodules of a freshly cloned repository for
+// --submodules include (top level only) or recurse. With a subdirectory only the
+// submodules inside it are initialized. Failures are reported and skipped so the
+// rest of the repository is still processed.
+func initClonedSubmodules(repo *git.Repository, url, subdir string) error {
+	// Name the real remote as origin rather than the cache the clone came from
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	if origin, ok := cfg.Remotes[git.DefaultRemoteName]; ok {
+		origin.URLs = []string{url}
+		if err := repo.SetConfig(cfg); err != nil {
+			return err
+		}
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if subdir != "" {
+		// A sparse checkout leaves out the root .gitmodules that lists the submodules
+		if err := restoreGitmodules(repo, worktree.Filesystem.Root()); err != nil {
+			return err
+		}
+	}
+	submodules, err := worktree.Submodules()
+	if err != nil {
+		return err
+	}
+
+	depth := git.NoRecurseSubmodules
+	if submoduleMode == "recurse" {
+		depth = git.DefaultSubmoduleRecursionDepth
+	}
+	for _, sub := range submodules {
+		path := sub.Config().Path
+		if subdir != "" && path != subdir && !strings.HasPrefix(path, subdir+"/") {
+			continue
+		}
+		// go-git would resolve relative URLs against the working directory
+		sub.Config().URL = resolveSubmoduleURL(url, sub.Config().URL)
+		fmt.Fprintf(logOut, "Initializing submodule '%s'...\n", path)
+		if err := sub.Update(&git.SubmoduleUpdateOptions{Init: true, RecurseSubmodules: depth}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not initialize submodule %s: %v\n", path, err)
+		}
+	}
+	return nil
+}
+
+// resolveSubmoduleURL resolves a "./" or "../" submodule URL against the URL of
+// the superproject, as git does; other URLs are returned unchanged.
+func resolveSubmoduleURL(base, rel string) string {
+	if !strings.HasPrefix(rel, "./") && !strings.HasPrefix(rel, "../") {
+		return rel
+	}
+	if u, err := neturl.Parse(base); err == nil && u.Scheme != "" {
+		u.Path = path.Join(u.Path, rel)
+		return u.String()
+	}
+	if host, repoPath, ok := strings.Cut(base, ":"); ok && !strings.Contains(host, "/") {
+		return host + ":" + path.Join(repoPath, rel) // scp-like git@host:org/repo.git
+	}
+	return path.Join(base, rel)
+}
+
+// restoreGitmodules writes .gitmodules from HEAD into dir when it wasn't checked out.
+func restoreGitmodules(repo *git.Repository, dir string) error {
+	target := filepath.Join(dir, ".gitmodules")
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	file, err := commit.File(".gitmodules")
+	if err != nil {
+		return nil // No submodules
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, []byte(contents), 0o644)
+}
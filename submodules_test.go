@@ -0,0 +1,282 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// stageSubmodule adds a submodule at path, pinned to sha, to the index of the
+// repository with its working tree in dir, and lists it in .gitmodules.
+func stageSubmodule(t *testing.T, repo *git.Repository, dir, path, url, sha string) {
+	t.Helper()
+	gitmodules := filepath.Join(dir, ".gitmodules")
+	existing, _ := os.ReadFile(gitmodules)
+	entry := "[submodule \"" + path + "\"]\n\tpath = " + path + "\n\turl = " + url + "\n"
+	writeTestFile(t, gitmodules, string(existing)+entry)
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add(".gitmodules"); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Entries = append(idx.Entries, &index.Entry{Name: path, Mode: filemode.Submodule, Hash: plumbing.NewHash(sha)})
+	if err := repo.Storer.SetIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkOutSubmodule makes dir a repository holding files, as a checked out submodule is.
+func checkOutSubmodule(t *testing.T, dir string, files map[string]string) *git.Repository {
+	t.Helper()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(dir, filepath.FromSlash(name)), content)
+	}
+	return repo
+}
+
+const (
+	libSHA   = "1111111111111111111111111111111111111111"
+	innerSHA = "2222222222222222222222222222222222222222"
+	emptySHA = "3333333333333333333333333333333333333333"
+)
+
+// submoduleTestRepo creates a repository with a checked out submodule lib, which
+// has a checked out submodule of its own (lib/inner), and a submodule empty that
+// isn't checked out.
+func submoduleTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	repo := newTestRepo(t)
+	repo.write(t, "main.go", "package main\n")
+	repo.write(t, "pkg/util.go", "package pkg\n")
+	lib := checkOutSubmodule(t, repo.path("lib"), map[string]string{"lib.go": "package lib\n"})
+	stageSubmodule(t, lib, repo.path("lib"), "inner", "https://example.com/inner.git", innerSHA)
+	checkOutSubmodule(t, repo.path("lib/inner"), map[string]string{"inner.go": "package inner\n"})
+	stageSubmodule(t, repo.repo, repo.dir, "lib", "https://example.com/lib.git", libSHA)
+	stageSubmodule(t, repo.repo, repo.dir, "empty", "https://example.com/empty.git", emptySHA)
+	if err := os.Mkdir(repo.path("empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestSubmoduleIndex(t *testing.T) {
+	repo := submoduleTestRepo(t)
+
+	// The repository is found from any directory inside it
+	for _, root := range []string{repo.dir, repo.path("pkg")} {
+		submodules := newSubmoduleIndex(root)
+		if commit, level, ok := submodules.lookup(repo.path("lib")); !ok || commit != libSHA || level != 1 {
+			t.Errorf("from %s: lookup(lib) = %s, %d, %v; want %s, 1, true", root, commit, level, ok, libSHA)
+		}
+	}
+
+	submodules := newSubmoduleIndex(repo.dir)
+	if _, _, ok := submodules.lookup(repo.path("main.go")); ok {
+		t.Error("lookup(main.go) found a submodule")
+	}
+	// Nested submodules become known once their parent submodule is entered
+	if _, _, ok := submodules.lookup(repo.path("lib/inner")); ok {
+		t.Error("lookup(lib/inner) found a submodule before entering lib")
+	}
+	submodules.enter(repo.path("lib"), 1)
+	if commit, level, ok := submodules.lookup(repo.path("lib/inner")); !ok || commit != innerSHA || level != 2 {
+		t.Errorf("lookup(lib/inner) = %s, %d, %v; want %s, 2, true", commit, level, ok, innerSHA)
+	}
+
+	if _, _, ok := newSubmoduleIndex(t.TempDir()).lookup(repo.path("lib")); ok {
+		t.Error("an index outside any repository found a submodule")
+	}
+}
+
+func TestSubmoduleSelected(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []bool // Levels 1 and 2
+	}{
+		{"skip", []bool{false, false}},
+		{"include", []bool{true, false}},
+		{"recurse", []bool{true, true}},
+	}
+	for _, tt := range tests {
+		setFlag(t, &submoduleMode, tt.mode)
+		for i, want := range tt.want {
+			if got := submoduleSelected(i + 1); got != want {
+				t.Errorf("submoduleSelected(%d) with %s = %v, want %v", i+1, tt.mode, got, want)
+			}
+		}
+	}
+}
+
+func TestWalkSubmodules(t *testing.T) {
+	repo := submoduleTestRepo(t)
+	resetSkipReport(t)
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	setFlag(t, &changedOnly, false)
+	setFlag(t, &sinceRef, "")
+	setFlag(t, &diffRef, "")
+
+	tests := []struct {
+		mode string
+		want map[string]string // Path -> pinned commit and skip reason of submodules, "" for files
+	}{
+		{"skip", map[string]string{
+			"main.go":     "",
+			"pkg/util.go": "",
+			"empty":       emptySHA + " " + submoduleSkipReason,
+			"lib":         libSHA + " " + submoduleSkipReason,
+		}},
+		{"include", map[string]string{
+			"main.go":     "",
+			"pkg/util.go": "",
+			"empty":       emptySHA + " " + submoduleUninitializedReason,
+			"lib":         libSHA + " ",
+			"lib/lib.go":  "",
+			"lib/inner":   innerSHA + " " + submoduleSkipReason,
+		}},
+		{"recurse", map[string]string{
+			"main.go":            "",
+			"pkg/util.go":        "",
+			"empty":              emptySHA + " " + submoduleUninitializedReason,
+			"lib":                libSHA + " ",
+			"lib/lib.go":         "",
+			"lib/inner":          innerSHA + " ",
+			"lib/inner/inner.go": "",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			setFlag(t, &submoduleMode, tt.mode)
+			files, err := processLocalPath(repo.dir, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, file := range files {
+				if file.IsDir && file.Submodule == "" {
+					continue
+				}
+				rel, _ := filepath.Rel(repo.dir, file.Path)
+				got[filepath.ToSlash(rel)] = ""
+				if file.Submodule != "" {
+					got[filepath.ToSlash(rel)] = file.Submodule + " " + file.SkipReason
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("walked %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// submoduleTestRemotes creates a remote lib and a remote super that has lib as
+// submodule "lib", with the URL libURL returns, next to docs/README.md.
+func submoduleTestRemotes(t *testing.T, libURL func(super, lib *testRemote) string) (*testRemote, *testRemote) {
+	t.Helper()
+	lib := newTestRemote(t)
+	libSHA := lib.commit(t, "lib.go", "package lib\n")
+	super := newTestRemote(t)
+	super.commit(t, "docs/README.md", "# Docs\n")
+	stageSubmodule(t, super.work, super.dir, "lib", libURL(super, lib), libSHA)
+	worktree, err := super.work.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	if _, err := worktree.Commit("Add lib", &git.CommitOptions{Author: signature}); err != nil {
+		t.Fatal(err)
+	}
+	super.push(t)
+	return super, lib
+}
+
+func TestInitClonedSubmodules(t *testing.T) {
+	absoluteURL := func(_, lib *testRemote) string { return lib.url }
+	tests := []struct {
+		name, mode, subdir string
+		wantLib            bool
+	}{
+		{"skip", "skip", "", false},
+		{"include", "include", "", true},
+		{"recurse", "recurse", "", true},
+		{"subdir holding the submodule", "include", "lib", true},
+		{"subdir beside the submodule", "include", "docs", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useClone(t)
+			setFlag(t, &submoduleMode, tt.mode)
+			super, _ := submoduleTestRemotes(t, absoluteURL)
+			dir, _, err := cloneForTest(t, super.url, "", tt.subdir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readCloned(dir, "lib/lib.go"); (got != "") != tt.wantLib {
+				t.Errorf("lib/lib.go = %q, want it checked out: %v", got, tt.wantLib)
+			}
+		})
+	}
+}
+
+func TestInitClonedSubmodulesRelativeURL(t *testing.T) {
+	// A relative URL resolves against the repository's own URL, not the cache it
+	// was cloned from
+	useTestCache(t)
+	setFlag(t, &submoduleMode, "include")
+	setFlag(t, &sinceRef, "")
+	setFlag(t, &diffRef, "")
+	relativeURL := func(super, lib *testRemote) string {
+		superPath := strings.TrimPrefix(super.url, "file://")
+		libPath := strings.TrimPrefix(lib.url, "file://")
+		rel, err := filepath.Rel(filepath.Dir(superPath), libPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "../" + filepath.ToSlash(rel)
+	}
+	super, _ := submoduleTestRemotes(t, relativeURL)
+	dir, _, err := cloneForTest(t, super.url, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readCloned(dir, "lib/lib.go"); got != "package lib\n" {
+		t.Errorf("lib/lib.go = %q, want the submodule checked out", got)
+	}
+}
+
+func TestResolveSubmoduleURL(t *testing.T) {
+	tests := []struct{ base, rel, want string }{
+		{"https://example.com/org/repo.git", "../lib.git", "https://example.com/org/lib.git"},
+		{"https://example.com/org/repo.git", "./lib.git", "https://example.com/org/repo.git/lib.git"},
+		{"https://example.com/org/repo", "../../other/lib", "https://example.com/other/lib"},
+		{"file:///srv/git/repo.git", "../lib.git", "file:///srv/git/lib.git"},
+		{"git@example.com:org/repo.git", "../lib.git", "git@example.com:org/lib.git"},
+		{"/srv/git/repo.git", "../lib.git", "/srv/git/lib.git"},
+		{"https://example.com/org/repo.git", "https://example.com/lib.git", "https://example.com/lib.git"},
+		{"https://example.com/org/repo.git", "git@example.com:lib.git", "git@example.com:lib.git"},
+	}
+	for _, tt := range tests {
+		if got := resolveSubmoduleURL(tt.base, tt.rel); got != tt.want {
+			t.Errorf("resolveSubmoduleURL(%q, %q) = %q, want %q", tt.base, tt.rel, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// defaultTextTemplate reproduces the classic tree/files/both text layout.
+// Users can start from this when writing their own --template.
+const defaultTextTemplate = `
+{{- if .ShowTree}}{{.TreeText}}{{if eq .OutputFormat "both"}}{{"\n"}}{{end}}{{end}}
+{{- if .ShowFiles}}{{range .Files}}File: {{.Path}}
+{{if $.TokensEnabled}}{{if .Error}}Tokens: Error ({{.Error}})
+{{else}}Tokens: {{.Tokens}}{{with .OriginalTokens}} (original: {{.}}){{end}}
+{{end}}{{end}}{{.GitContext}}{{repeat "=" 50}}
+{{with .ReadError}}Error reading file: {{.}}
+{{else}}{{ensureNewline .Content}}{{end}}
+{{end}}{{end}}
+{{.SummaryText}}`
+
+// templateFuncs are the helper functions available to output templates.
+var templateFuncs = template.FuncMap{
+	"repeat":        strings.Repeat,
+	"ensureNewline": ensureNewline,
+	"trimSpace":     strings.TrimSpace,
+	"lower":         strings.ToLower,
+	"upper":         strings.ToUpper,
+	"replace":       strings.ReplaceAll,
+	"base":          filepath.Base,
+	"ext":           filepath.Ext,
+}
+
+// TemplateFile is the per-file value exposed to output templates.
+// Content is the emitted content kept from processing, loaded from the run's
+// contentStore on first use, so templates that only list paths never load it.
+type TemplateFile struct {
+	Path           string
+	Size           int64
+	Mode           fs.FileMode
+	Tokens         int
+	OriginalTokens int // Tokens before truncation/compression, 0 if the content is unchanged
+	Language       string
+	Error          error         // Error recorded while processing (e.g. during token counting)
+	Skip           string        // Reason the content is withheld, only set in SkippedFiles
+	Binary         bool          // Detected as binary; Content is the --include-binary encoding
+	Change         string        // A, M, D or R with --changed/--since
+	History        []CommitInfo  // Recent commits with --git-log
+	Blame          *BlameSummary // Top authors and last change with --blame-summary
+	GitContext     string        // History and blame rendered as text, "" without them
+
+	file     FileInfo
+	contents *contentStore // Holds the content, nil for withheld content
+	content  []byte
+	loaded   bool
+	readErr  error
+}
+
+// Content returns the emitted file content, loading it on first use.
+func (f *TemplateFile) Content() string {
+	f.load()
+	return string(f.content)
+}
+
+// ReadError returns the error encountered while reading the content, if any.
+func (f *TemplateFile) ReadError() error {
+	f.load()
+	return f.readErr
+}
+
+// load loads the file content once.
+func (f *TemplateFile) load() {
+	if f.loaded {
+		return
+	}
+	f.loaded = true
+	if f.contents != nil {
+		f.content, f.readErr = f.contents.content(f.file)
+	}
+}
+
+// TemplateData is the root value passed to output templates.
+type TemplateData struct {
+	Inputs        []string        // Input paths/URLs as given on the command line
+	Version       string          // Iris version
+	OutputFormat  string          // Value of --output
+	ShowTree      bool            // Whether --output includes the tree
+	ShowFiles     bool            // Whether --output includes file contents
+	TokensEnabled bool            // Whether token counts are available
+	Tree          *Node           // Directory tree, nil unless a single directory was processed
+	TreeText      string          // Rendered tree (or flat file list when Tree is nil)
+	Files         []*TemplateFile // Files sorted by path
+	SkippedFiles  []*TemplateFile // Files listed in the tree whose content is withheld
+	Summary       Summary         // Aggregated totals
+	SummaryText   string          // Rendered "--- Summary ---" block
+}
+
+// newTemplateData assembles the template input from the processed files. The
+// content of Files is loaded from contents.
+func newTemplateData(files []FileInfo, inputs []string, summary Summary, langData *LoadedLanguageData, contents *contentStore) TemplateData {
+	sort.Slice(files, func(i, j int) bool { // Sort by path for consistent output
+		return files[i].Path < files[j].Path
+	})
+
+	data := TemplateData{
+		Inputs:        inputs,
+		Version:       version,
+		OutputFormat:  outputFormat,
+		ShowTree:      formatShowsTree(outputFormat),
+		ShowFiles:     formatShowsFiles(outputFormat),
+		TokensEnabled: !disableTokens,
+		Tree:          treeRootFor(files, inputs),
+		TreeText:      renderTreeSection(files, inputs),
+		Summary:       summary,
+		SummaryText:   formatSummary(summary, !disableTokens),
+	}
+	for _, file := range emittedFiles(files) {
+		tf := newTemplateFile(file, langData)
+		tf.file, tf.contents = file, contents
+		data.Files = append(data.Files, tf)
+	}
+	for _, file := range files {
+		if !file.IsDir && file.SkipReason != "" {
+			data.SkippedFiles = append(data.SkippedFiles, newTemplateFile(file, langData))
+		}
+	}
+	return data
+}
+
+// newTemplateFile converts a processed file into its template value.
+func newTemplateFile(file FileInfo, langData *LoadedLanguageData) *TemplateFile {
+	return &TemplateFile{
+		Path:           file.Path,
+		Size:           file.Size,
+		Mode:           file.Mode,
+		Tokens:         file.TokenCount,
+		OriginalTokens: file.OriginalTokenCount,
+		Language:       languageForFile(file, langData),
+		Error:          file.Error,
+		Skip:           file.SkipReason,
+		Binary:         file.Binary,
+		Change:         file.ChangeStatus,
+		History:        file.History,
+		Blame:          file.Blame,
+		GitContext:     formatGitContext(file),
+	}
+}
+
+// loadOutputTemplate parses the template at path, or the built-in default when path is empty.
+func loadOutputTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return template.New("default").Funcs(templateFuncs).Parse(defaultTextTemplate)
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template %s: %w", path, err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template %s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// ensureNewline appends a trailing newline to non-empty text that lacks one.
+func ensureNewline(text string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		return text + "\n"
+	}
+	return text
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// templateTestFiles writes the files of a small project to proj/ in a temporary
+// working directory and processes them, counting one token per byte.
+func templateTestFiles(t *testing.T) ([]FileInfo, *contentStore) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	var files []FileInfo
+	for _, file := range []struct{ name, content string }{
+		{"main.go", "package main\n\nfunc main() {}\n"},
+		{"a.txt", "hello\n"},
+		{"readme.md", "# Title\nno newline"},
+	} {
+		path := filepath.Join("proj", file.name)
+		writeTestFile(t, path, file.content)
+		source, sourcePath := fileSource(path)
+		files = append(files, FileInfo{Path: path, Size: int64(len(file.content)), Mode: 0o644, Source: source, SourcePath: sourcePath})
+	}
+	processed, store, err := processFiles(files, byteTokenizer{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return processed, store
+}
+
+func TestDefaultTemplateMatchesPreviousOutput(t *testing.T) {
+	// The text output as it was written before templates, for the files of templateTestFiles
+	const tree = "proj\n├── a.txt\n├── main.go\n└── readme.md\n"
+	previousFiles := func(tokens bool) string {
+		var b strings.Builder
+		for _, file := range []struct{ path, tokens, content string }{
+			{"proj/a.txt", "6", "hello\n"},
+			{"proj/main.go", "29", "package main\n\nfunc main() {}\n"},
+			{"proj/readme.md", "18", "# Title\nno newline\n"},
+		} {
+			b.WriteString("File: " + filepath.FromSlash(file.path) + "\n")
+			if tokens {
+				b.WriteString("Tokens: " + file.tokens + "\n")
+			}
+			b.WriteString(strings.Repeat("=", 50) + "\n" + file.content + "\n")
+		}
+		return b.String()
+	}
+	previousSummary := func(tokens bool) string {
+		summary := "\n--- Summary ---\nTotal files processed: 3\nTotal size: 53 bytes\n"
+		if tokens {
+			summary += "Total tokens: 53\n"
+		}
+		return summary
+	}
+
+	setFlag[io.Writer](t, &logOut, io.Discard)
+	setFlag(t, &resultFormat, "text")
+	tmpl, err := loadOutputTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{"tree", "files", "both"} {
+		for _, tokens := range []bool{true, false} {
+			setFlag(t, &outputFormat, format)
+			setFlag(t, &disableTokens, !tokens)
+			files, store := templateTestFiles(t)
+
+			var want string
+			switch format {
+			case "tree":
+				want = tree + previousSummary(tokens)
+			case "files":
+				want = previousFiles(tokens) + previousSummary(tokens)
+			case "both":
+				want = tree + "\n" + previousFiles(tokens) + previousSummary(tokens)
+			}
+
+			summary := Summary{}
+			summarizeFiles(&summary, files, byteTokenizer{})
+			renderer := outputRenderer{template: tmpl, inputs: []string{"proj"}, contents: store}
+			var out strings.Builder
+			if err := renderer.write(&out, files, summary); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != want {
+				t.Errorf("-o %s (tokens %v) =\n%s\nwant\n%s", format, tokens, out.String(), want)
+			}
+		}
+	}
+}
+
+func TestDefaultTemplateReadErrors(t *testing.T) {
+	setFlag(t, &resultFormat, "text")
+	setFlag(t, &outputFormat, "files")
+	setFlag(t, &disableTokens, false)
+	tmpl, err := loadOutputTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readErr := errors.New("permission denied")
+	files, store := storedTestFiles(t, []FileInfo{{Path: "secret.txt", Error: readErr}}, nil)
+	renderer := outputRenderer{template: tmpl, inputs: []string{"secret.txt"}, contents: store}
+	var out strings.Builder
+	if err := renderer.write(&out, files, Summary{}); err != nil {
+		t.Fatal(err)
+	}
+	want := "File: secret.txt\nTokens: Error (permission denied)\n" + strings.Repeat("=", 50) + "\nError reading file: permission denied\n\n" +
+		"\n--- Summary ---\nTotal files processed: 0\nTotal size: 0 bytes\nTotal tokens: 0\n"
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestLoadOutputTemplateErrors(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.tmpl")
+	writeTestFile(t, broken, "{{range .Files}}{{.Path}}\n")
+	missing := filepath.Join(dir, "missing.tmpl")
+
+	for path, want := range map[string]string{
+		broken:  "error parsing template " + broken,
+		missing: "error reading template " + missing,
+	} {
+		if _, err := loadOutputTemplate(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("loadOutputTemplate(%s) error = %v, want it to contain %q", filepath.Base(path), err, want)
+		}
+	}
+	if _, err := loadOutputTemplate(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("loadOutputTemplate(missing) error = %v, want it to wrap os.ErrNotExist", err)
+	}
+}
+
+func TestTemplateExecutionError(t *testing.T) {
+	setFlag(t, &resultFormat, "text")
+	setFlag(t, &outputFormat, "both")
+	path := filepath.Join(t.TempDir(), "bad.tmpl")
+	writeTestFile(t, path, "{{range .Files}}{{.NoSuchField}}{{end}}")
+	tmpl, err := loadOutputTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, store := storedTestFiles(t, []FileInfo{{Path: "a.txt"}}, []string{"a\n"})
+	renderer := outputRenderer{template: tmpl, inputs: []string{"a.txt"}, contents: store}
+	err = renderer.write(io.Discard, files, Summary{})
+	if err == nil || !strings.Contains(err.Error(), "NoSuchField") {
+		t.Errorf("write() error = %v, want the template's execution error", err)
+	}
+}
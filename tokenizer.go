@@ -64,7 +64,7 @@ const defaultHFModel = "gpt2"         // Default if tokenizer is huggingface and
 // getTokenizer returns a tokenizer instance based on flags.
 // It returns a Tokenizer interface.
 func getTokenizer() (Tokenizer, error) {
-	fmt.Printf("Initializing tokenizer (Type: %s, Model: %s, File: %s)\n", tokenizerType, tokenizerModel, tokenizerFile)
+	fmt.Fprintf(logOut, "Initializing tokenizer (Type: %s, Model: %s, File: %s)\n", tokenizerType, tokenizerModel, tokenizerFile)
 
 	switch strings.ToLower(tokenizerType) {
 	case "tiktoken":
@@ -80,12 +80,12 @@ func loadTiktoken() (Tokenizer, error) {
 	model := tokenizerModel
 	if model == "" {
 		model = defaultTiktokenModel
-		fmt.Printf("No Tiktoken model specified, using default: %s\n", model)
+		fmt.Fprintf(logOut, "No Tiktoken model specified, using default: %s\n", model)
 	}
 
 	tke, err := tiktoken.EncodingForModel(model)
 	if err != nil {
-		fmt.Printf("Warning: Tiktoken model '%s' not found, falling back to default '%s'. Error: %v\n", model, defaultTiktokenModel, err)
+		fmt.Fprintf(logOut, "Warning: Tiktoken model '%s' not found, falling back to default '%s'. Error: %v\n", model, defaultTiktokenModel, err)
 		tke, err = tiktoken.EncodingForModel(defaultTiktokenModel)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get tiktoken encoding for default model '%s': %w", defaultTiktokenModel, err)
@@ -97,7 +97,7 @@ func loadTiktoken() (Tokenizer, error) {
 func loadHuggingFace() (Tokenizer, error) {
 	if tokenizerFile != "" {
 		// Load from local file
-		fmt.Printf("Loading HuggingFace tokenizer from file: %s\n", tokenizerFile)
+		fmt.Fprintf(logOut, "Loading HuggingFace tokenizer from file: %s\n", tokenizerFile)
 		ttk, err := pretrained.FromFile(tokenizerFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load tokenizer from file %s: %w", tokenizerFile, err)
@@ -108,9 +108,9 @@ func loadHuggingFace() (Tokenizer, error) {
 		model := tokenizerModel
 		if model == "" {
 			model = defaultHFModel
-			fmt.Printf("No HuggingFace model specified, using default: %s\n", model)
+			fmt.Fprintf(logOut, "No HuggingFace model specified, using default: %s\n", model)
 		}
-		fmt.Printf("Loading HuggingFace tokenizer for model: %s (this may download files)\n", model)
+		fmt.Fprintf(logOut, "Loading HuggingFace tokenizer for model: %s (this may download files)\n", model)
 
 		// sugarme/tokenizer uses CachedPath to download/find the tokenizer.json
 		// We need the identifier used on the Hub (e.g., "bert-base-uncased")
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// truncationEnabled reports whether --truncate-lines or --truncate-tokens is set.
+func truncationEnabled() bool {
+	return truncateLines > 0 || truncateTokens > 0
+}
+
+// readTruncated reads a file larger than --max-size, which is kept because truncation
+// is enabled, without loading all of it: only its first and last halves of --max-size
+// bytes are read, cut to whole lines and joined by a marker for the bytes skipped in
+// between. Truncation then applies to that excerpt.
+func readTruncated(file FileInfo) ([]byte, error) {
+	f, err := file.Source.Open(file.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	half := max(maxSizeBytes/2, 1) // Even a tiny --max-size keeps a byte of each end
+	head := make([]byte, half)
+	if _, err := io.ReadFull(f, head); err != nil {
+		return nil, err
+	}
+	tail := make([]byte, half)
+	if readerAt, ok := f.(io.ReaderAt); ok {
+		_, err = readerAt.ReadAt(tail, file.Size-half)
+	} else {
+		// Sources without random access are read through, discarding the middle
+		if _, err = io.CopyN(io.Discard, f, file.Size-2*half); err == nil {
+			_, err = io.ReadFull(f, tail)
+		}
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// Keep whole lines only, unless a line is longer than the excerpt itself
+	if i := bytes.LastIndexByte(head, '\n'); i >= 0 {
+		head = head[:i+1]
+	}
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	marker := fmt.Sprintf("... [%d bytes omitted] ...\n", file.Size-int64(len(head)+len(tail)))
+	content := make([]byte, 0, len(head)+len(marker)+len(tail))
+	content = append(content, head...)
+	if len(head) > 0 && head[len(head)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	content = append(content, marker...)
+	return append(content, tail...), nil
+}
+
+// estimateOriginalTokens extrapolates the token count of a whole file from the
+// tokens of the excerpt readTruncated read from it, in proportion to their sizes.
+func estimateOriginalTokens(file FileInfo, excerpt []byte, tk Tokenizer) int {
+	if len(excerpt) == 0 {
+		return 0
+	}
+	return int(int64(tk.CountTokens(string(excerpt))) * file.Size / int64(len(excerpt)))
+}
+
+// exceedsMaxSize reports whether file is larger than --max-size while truncation is
+// enabled, so only its head and tail are read.
+func exceedsMaxSize(file FileInfo) bool {
+	return truncationEnabled() && maxSizeBytes > 0 && file.Size > maxSizeBytes
+}
+
+// truncateContent keeps the head and tail of content that exceeds --truncate-lines
+// or --truncate-tokens, replacing the middle with an explicit omission marker.
+// Truncation works on whole lines so code stays readable. tk may be nil, in which
+// case only the line limit applies. It reports whether anything was removed.
+func truncateContent(content []byte, tk Tokenizer) ([]byte, bool) {
+	lines := strings.SplitAfter(string(content), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1] // SplitAfter leaves an empty element after a trailing newline
+	}
+	n := len(lines)
+
+	// head and tail are the number of lines kept from each end
+	head, tail := n, 0
+	lineLimited := truncateLines > 0 && n > truncateLines
+	if lineLimited {
+		head, tail = (truncateLines+1)/2, truncateLines/2
+	}
+
+	var costs []int
+	if truncateTokens > 0 && tk != nil {
+		costs = make([]int, n)
+		kept := 0
+		for i, line := range lines {
+			costs[i] = tk.CountTokens(line)
+			if i < head || i >= n-tail {
+				kept += costs[i]
+			}
+		}
+		if kept > truncateTokens {
+			// The marker counts against the budget too; estimate it with the largest possible numbers
+			budget := truncateTokens - tk.CountTokens(fmt.Sprintf("... [%d lines / %d tokens omitted] ...\n", n, kept))
+			maxHead, maxTail := head, n
+			if lineLimited {
+				maxTail = tail
+			}
+			// Spend half of the budget on the head, the rest (including unused head budget) on the tail
+			h, used := 0, 0
+			for h < maxHead && used+costs[h] <= budget/2 {
+				used += costs[h]
+				h++
+			}
+			t := 0
+			for t < maxTail && n-1-t >= h && used+costs[n-1-t] <= budget {
+				used += costs[n-1-t]
+				t++
+			}
+			head, tail = h, t
+		}
+	}
+
+	if head+tail >= n {
+		return content, false
+	}
+
+	omitted := lines[head : n-tail]
+	var marker string
+	if tk != nil {
+		omittedTokens := 0
+		if costs != nil {
+			for i := head; i < n-tail; i++ {
+				omittedTokens += costs[i]
+			}
+		} else {
+			omittedTokens = tk.CountTokens(strings.Join(omitted, ""))
+		}
+		marker = fmt.Sprintf("... [%d lines / %d tokens omitted] ...\n", len(omitted), omittedTokens)
+	} else {
+		marker = fmt.Sprintf("... [%d lines omitted] ...\n", len(omitted))
+	}
+
+	var builder strings.Builder
+	for _, line := range lines[:head] {
+		builder.WriteString(line)
+	}
+	if head > 0 && !strings.HasSuffix(lines[head-1], "\n") {
+		builder.WriteString("\n")
+	}
+	builder.WriteString(marker)
+	for _, line := range lines[n-tail:] {
+		builder.WriteString(line)
+	}
+	return []byte(builder.String()), true
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// numberedLines returns n lines holding their 1-based line numbers.
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+// setTruncation sets the truncation flags for the duration of a test.
+func setTruncation(t *testing.T, lines, tokens int) {
+	t.Helper()
+	oldLines, oldTokens := truncateLines, truncateTokens
+	truncateLines, truncateTokens = lines, tokens
+	t.Cleanup(func() { truncateLines, truncateTokens = oldLines, oldTokens })
+}
+
+func TestTruncateContent(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		lines, tokens int
+		tk            Tokenizer
+		want          string
+		truncated     bool
+	}{
+		{
+			name:    "no limits",
+			content: numberedLines(10),
+			want:    numberedLines(10),
+		},
+		{
+			name:    "within line limit",
+			content: numberedLines(4),
+			lines:   4,
+			want:    numberedLines(4),
+		},
+		{
+			name:      "even line limit",
+			content:   numberedLines(10),
+			lines:     4,
+			want:      "1\n2\n... [6 lines omitted] ...\n9\n10\n",
+			truncated: true,
+		},
+		{
+			name:      "odd line limit keeps the extra line at the head",
+			content:   numberedLines(10),
+			lines:     3,
+			want:      "1\n2\n... [7 lines omitted] ...\n10\n",
+			truncated: true,
+		},
+		{
+			name:      "no trailing newline",
+			content:   "a\nb\nc\nd",
+			lines:     2,
+			want:      "a\n... [2 lines omitted] ...\nd",
+			truncated: true,
+		},
+		{
+			name:      "line limit with token counts in the marker",
+			content:   numberedLines(10),
+			lines:     4,
+			tk:        byteTokenizer{},
+			want:      "1\n2\n... [6 lines / 12 tokens omitted] ...\n9\n10\n",
+			truncated: true,
+		},
+		{
+			name:      "token limit",
+			content:   strings.Repeat("abcd\n", 20),
+			tokens:    60,
+			tk:        byteTokenizer{},
+			want:      "abcd\nabcd\n... [16 lines / 80 tokens omitted] ...\nabcd\nabcd\n",
+			truncated: true,
+		},
+		{
+			name:    "token limit without a tokenizer",
+			content: numberedLines(10),
+			tokens:  1,
+			want:    numberedLines(10),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTruncation(t, tt.lines, tt.tokens)
+			got, truncated := truncateContent([]byte(tt.content), tt.tk)
+			if string(got) != tt.want || truncated != tt.truncated {
+				t.Errorf("truncateContent() = %q, %v; want %q, %v", got, truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	setTruncation(t, 4, 0)
+	tests := []struct {
+		name    string
+		maxSize int64
+		content string
+		want    string
+	}{
+		{
+			name:    "whole lines",
+			maxSize: 8,
+			content: numberedLines(20), // 9 one-digit and 11 two-digit lines, 51 bytes
+			want:    "1\n2\n... [44 bytes omitted] ...\n20\n",
+		},
+		{
+			name:    "line longer than the excerpt",
+			maxSize: 6,
+			content: "first line\nlast line",
+			want:    "fir\n... [14 bytes omitted] ...\nine",
+		},
+		{
+			name:    "one byte keeps a byte of each end",
+			maxSize: 1,
+			content: "first line\nlast line",
+			want:    "f\n... [18 bytes omitted] ...\ne",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &maxSizeBytes, tt.maxSize)
+			fsys := fstest.MapFS{"big.txt": {Data: []byte(tt.content)}}
+			file := FileInfo{Path: "big.txt", Size: int64(len(tt.content)), Source: fsys, SourcePath: "big.txt"}
+			if !exceedsMaxSize(file) {
+				t.Fatal("exceedsMaxSize() = false for a file over --max-size")
+			}
+
+			got, err := readTruncated(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("readTruncated() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessContentEstimatesOriginalTokens(t *testing.T) {
+	setTruncation(t, 4, 0)
+	setFlag(t, &maxSizeBytes, 100)
+	content := strings.Repeat("0123456789\n", 100) // 1100 bytes
+	fsys := fstest.MapFS{"big.txt": {Data: []byte(content)}}
+	file := FileInfo{Path: "big.txt", Size: int64(len(content)), Source: fsys, SourcePath: "big.txt"}
+
+	excerpt, err := readTruncated(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	processed, emitted := processContent(file, excerpt, byteTokenizer{})
+	if processed.TokenCount != len(emitted) {
+		t.Errorf("TokenCount = %d, want the %d bytes emitted", processed.TokenCount, len(emitted))
+	}
+	// The excerpt's marker makes the estimate a little high, but it is close to the file's size
+	if got := processed.OriginalTokenCount; got < len(content) || got > len(content)*5/4 {
+		t.Errorf("OriginalTokenCount = %d, want about %d", got, len(content))
+	}
+}
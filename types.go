@@ -1,23 +1,118 @@
 package main
 
-import "io/fs"
+import (
+	"io/fs"
+	"time"
+)
 
 // FileInfo holds information about a processed file.
 type FileInfo struct {
-	Path       string
-	Size       int64
-	Mode       fs.FileMode
-	Content    []byte // Content might be loaded conditionally based on output format
-	TokenCount int    // Populated if token counting is enabled
-	IsDir      bool   // Indicates if this is a directory entry
-	Error      error  // Stores any error encountered while processing this file/dir
+	Path               string // Display path (relative to the repository root for Git URL inputs)
+	DiskPath           string // Location on disk when it differs from Path (e.g. inside a clone), to find its git repository
+	Source             fs.FS  // File system the content is read from (see inputSource)
+	SourcePath         string // Slash-separated path of the file inside Source
+	Size               int64
+	Mode               fs.FileMode
+	ModTime            time.Time        // Zero for entries without a filesystem timestamp (e.g. web pages)
+	Language           string           // Detected from the name and emitted content during processing, "" if unknown
+	TokenCount         int              // Populated if token counting is enabled, counts the emitted content
+	OriginalTokenCount int              // Tokens before content transformations (e.g. truncation), 0 if untransformed; estimated for files over --max-size
+	OutputTokens       int              // Tokens of the emitted content as the output format writes it, set with --max-tokens
+	IsDir              bool             // Indicates if this is a directory entry
+	Error              error            // Stores any error encountered while processing this file/dir
+	SkipReason         string           // Non-empty when the file stays in the tree but its content is withheld
+	Compressed         bool             // Comments/blank lines were stripped by --compress
+	Compression        *CompressionStat // Tokens before and after --compress, nil without token counting
+	Binary             bool             // Content sniffing classified the file as binary
+	Secrets            map[string]int   // Secrets found per rule by --redact/--fail-on-secrets
+	ChangeStatus       string           // A, M, D or R with --changed/--since, empty otherwise
+	Diff               *diffInput       // Ref side of the file with --diff, content becomes a unified diff
+	History            []CommitInfo     // Recent commits touching the file with --git-log
+	Blame              *BlameSummary    // Top authors and last change with --blame-summary
+	Submodule          string           // Pinned commit SHA when this directory is a git submodule
+	Archive            string           // Archive input the file was read from (in memory)
+	Stdin              bool             // Content was read from standard input
+	Emitted            storedContent    // Location of the emitted content in the run's contentStore
+}
+
+// GitPath returns the file's location on disk, used to find its git repository.
+func (f FileInfo) GitPath() string {
+	if f.DiskPath != "" {
+		return f.DiskPath
+	}
+	return f.Path
+}
+
+// ReadContent reads the file's content from its source.
+func (f FileInfo) ReadContent() ([]byte, error) {
+	if f.Source == nil {
+		return nil, &fs.PathError{Op: "read", Path: f.Path, Err: fs.ErrNotExist}
+	}
+	return fs.ReadFile(f.Source, f.SourcePath)
 }
 
 // Summary holds aggregated information about the processed items.
 type Summary struct {
-	TotalFiles  int
-	TotalSize   int64
-	TotalTokens int
+	TotalFiles  int   `json:"total_files"`
+	TotalSize   int64 `json:"total_size"`
+	TotalTokens int   `json:"total_tokens"`
+	FailedPaths int   `json:"failed_paths"` // Inputs that could not be processed at all
+
+	GitSources []GitSource `json:"git_sources,omitempty"` // Cloned repositories, for reproducible dumps
+
+	TokenBudget   int      `json:"token_budget,omitempty"`   // Value of --max-tokens, 0 if unlimited
+	OmittedFiles  []string `json:"omitted_files,omitempty"`  // Files dropped to fit the token budget
+	OmittedTokens int      `json:"omitted_tokens,omitempty"` // Tokens of the omitted files
+
+	Compression []CompressionStat `json:"compression,omitempty"` // Per-file results of --compress
+
+	Secrets         map[string]int `json:"secrets,omitempty"` // Secrets found per rule
+	SecretsRedacted bool           `json:"secrets_redacted,omitempty"`
+
+	History []RepoHistory `json:"history,omitempty"` // Recent commits per repository with --git-log-scope repo
+}
+
+// GitSource records the commit a cloned Git URL was read at.
+type GitSource struct {
+	URL    string `json:"url"`
+	Ref    string `json:"ref,omitempty"`    // Requested branch, tag or SHA, empty for the default branch
+	Subdir string `json:"subdir,omitempty"` // Directory selected with //subdir or --git-subdir
+	Commit string `json:"commit"`
+}
+
+// CommitInfo is one commit listed by --git-log.
+type CommitInfo struct {
+	Hash    string    `json:"hash"` // Abbreviated SHA
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+}
+
+// RepoHistory holds the recent commits of one repository.
+type RepoHistory struct {
+	Repository string       `json:"repository"` // Clone URL or local path
+	Commits    []CommitInfo `json:"commits"`
+}
+
+// BlameSummary condenses a file's blame for --blame-summary.
+type BlameSummary struct {
+	Authors      []AuthorLines `json:"authors"` // Top authors by line count
+	TotalLines   int           `json:"total_lines"`
+	LastModified time.Time     `json:"last_modified"` // Date of the most recently changed line
+	LastAuthor   string        `json:"last_author"`
+}
+
+// AuthorLines is the number of lines of a file last changed by one author.
+type AuthorLines struct {
+	Name  string `json:"name"`
+	Lines int    `json:"lines"`
+}
+
+// CompressionStat records the token counts of one file before and after --compress.
+type CompressionStat struct {
+	Path   string `json:"path"`
+	Before int    `json:"tokens_before"`
+	After  int    `json:"tokens_after"`
 }
 
 // ProcessedItem represents either a FileInfo or a directory structure node.
@@ -25,16 +25,16 @@ func processWebURLRecursive(startURL string, currentDepth, maxDepth int, visited
 	cleanURL := parsedURL.String()
 
 	if currentDepth > maxDepth {
-		fmt.Printf("Max depth (%d) reached, not processing: %s\n", maxDepth, cleanURL)
+		fmt.Fprintf(logOut, "Max depth (%d) reached, not processing: %s\n", maxDepth, cleanURL)
 		return nil, nil
 	}
 	if visited[cleanURL] {
-		fmt.Printf("Already visited, skipping: %s\n", cleanURL)
+		fmt.Fprintf(logOut, "Already visited, skipping: %s\n", cleanURL)
 		return nil, nil
 	}
 
 	visited[cleanURL] = true
-	fmt.Printf("Processing web URL (Depth %d): %s\n", currentDepth, cleanURL)
+	fmt.Fprintf(logOut, "Processing web URL (Depth %d): %s\n", currentDepth, cleanURL)
 
 	// --- Fetch and Process Current URL ---
 	res, err := http.Get(cleanURL)
@@ -53,7 +53,7 @@ func processWebURLRecursive(startURL string, currentDepth, maxDepth int, visited
 	// Check content type - only parse HTML
 	contentType := res.Header.Get("Content-Type")
 	if !strings.Contains(strings.ToLower(contentType), "text/html") {
-		fmt.Printf("Skipping non-HTML content type (%s) for URL: %s\n", contentType, cleanURL)
+		fmt.Fprintf(logOut, "Skipping non-HTML content type (%s) for URL: %s\n", contentType, cleanURL)
 		return nil, nil
 	}
 
@@ -77,13 +77,13 @@ func processWebURLRecursive(startURL string, currentDepth, maxDepth int, visited
 	var currentFiles []FileInfo
 	if err == nil { // Only add FileInfo if conversion was successful
 		fileInfo := FileInfo{
-			Path:    cleanURL, // Use the cleaned URL
-			Content: []byte(markdown),
-			Size:    int64(len(markdown)),
-			IsDir:   false,
+			Path:  cleanURL, // Use the cleaned URL
+			Size:  int64(len(markdown)),
+			IsDir: false,
 		}
+		fileInfo.Source, fileInfo.SourcePath = contentSource(cleanURL, []byte(markdown))
 		currentFiles = append(currentFiles, fileInfo)
-		fmt.Printf("Finished processing web URL: %s (Markdown size: %d bytes)\n", cleanURL, fileInfo.Size)
+		fmt.Fprintf(logOut, "Finished processing web URL: %s (Markdown size: %d bytes)\n", cleanURL, fileInfo.Size)
 	}
 	// --- End Conversion ---
 
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// xmlTextEscaper escapes character data so file contents containing tags
+// (HTML, XML, other prompts) cannot close or inject elements.
+var xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// xmlAttrEscaper additionally escapes quotes for use inside attribute values.
+var xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// printXML writes the 'xml' output format: the tree wrapped in <directory_structure>,
+// each file wrapped in a <file> element with path/tokens/language attributes, and the summary.
+func printXML(w io.Writer, files []FileInfo, tree string, summary string, includeTokens bool, langData *LoadedLanguageData, contents *contentStore) error {
+	out := bufio.NewWriter(w)
+
+	out.WriteString("<directory_structure>\n")
+	out.WriteString(xmlTextEscaper.Replace(tree))
+	if tree != "" && !strings.HasSuffix(tree, "\n") {
+		out.WriteString("\n")
+	}
+	out.WriteString("</directory_structure>\n\n")
+
+	sort.Slice(files, func(i, j int) bool { // Sort by path for consistent output
+		return files[i].Path < files[j].Path
+	})
+
+	out.WriteString("<files>\n")
+	for _, file := range emittedFiles(files) { // Skipped files only appear in the directory structure
+		content, readErr := contents.content(file)
+		writeXMLFile(out, file, content, readErr, includeTokens, langData)
+	}
+	out.WriteString("</files>\n\n")
+
+	out.WriteString("<summary>\n")
+	out.WriteString(xmlTextEscaper.Replace(summary))
+	out.WriteString("</summary>\n")
+
+	return out.Flush()
+}
+
+// writeXMLFile writes the <file> element of a single file with its emitted content.
+func writeXMLFile(out io.StringWriter, file FileInfo, content []byte, readErr error, includeTokens bool, langData *LoadedLanguageData) {
+	out.WriteString(fmt.Sprintf(`<file path="%s"`, xmlAttrEscaper.Replace(file.Path)))
+	if includeTokens && file.Error == nil {
+		out.WriteString(fmt.Sprintf(` tokens="%d"`, file.TokenCount))
+		if file.OriginalTokenCount > 0 {
+			out.WriteString(fmt.Sprintf(` original_tokens="%d"`, file.OriginalTokenCount))
+		}
+	}
+	if lang := languageForFile(file, langData); lang != "" {
+		out.WriteString(fmt.Sprintf(` language="%s"`, xmlAttrEscaper.Replace(lang)))
+	}
+	if file.Blame != nil && len(file.Blame.Authors) > 0 {
+		out.WriteString(fmt.Sprintf(` authors="%s"`, xmlAttrEscaper.Replace(formatBlameSummary(file.Blame))))
+	}
+	out.WriteString(">\n")
+	if len(file.History) > 0 {
+		out.WriteString("<git_log>\n")
+		out.WriteString(xmlTextEscaper.Replace(formatCommits(file.History, "")))
+		out.WriteString("</git_log>\n")
+	}
+
+	if readErr != nil {
+		out.WriteString(xmlTextEscaper.Replace(fmt.Sprintf("Error reading file: %v", readErr)))
+		out.WriteString("\n")
+	} else {
+		out.WriteString(xmlTextEscaper.Replace(string(content)))
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			out.WriteString("\n")
+		}
+	}
+	out.WriteString("</file>\n")
+}